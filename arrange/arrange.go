@@ -0,0 +1,676 @@
+// Package arrange is the engine behind arrangeit. Given a set of items (people or things) with tags, a set of rules
+// about those tags, and a set of groups to fill, it searches for the arrangement of items into groups that best
+// follows the rules.
+package arrange
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// TODO:
+//	- Avoid exploring states that can't possibly meet the min-size requirements
+//	- Better heuristics
+//	- Accept another data structure for groups (e.g. the cars/vans available)
+
+// Item defines a thing or person that has a set of tags and needs to be arranged into groups.
+type Item struct {
+	// ID must be unique to each item
+	ID string
+
+	// Map of tag names to tag values for this item
+	Tags map[string]string
+}
+
+// RuleType definitions control the behavior of a rule and can be found below.
+type RuleType string
+
+const (
+	// Try to keep items together that share the same value for this tag.
+	RuleTypeSameness RuleType = "Sameness"
+
+	// Interpret the tag value as the ID of another item (or a comma-separated list of IDs), and try to keep these items
+	// together.
+	RuleTypeRelationship RuleType = "Relationship"
+
+	// Try to interpret the given tag value as a geolocation and put nearby items together.
+	RuleTypeNearness RuleType = "Nearness"
+
+	// Try to put items in the groups whose value for the tag matches theirs, e.g. Spanish speakers at the tables with
+	// language "es". The groups' tag has the same name unless the rule has a groupTag param. Either value may be a
+	// comma-separated list, which matches if any of the values do. Groups without a value are left out. A negative
+	// weight keeps items out of the groups they match instead.
+	RuleTypeGroupMatch RuleType = "GroupMatch"
+
+	// Only allow items in a group with a value for the tag if they match it, the same as RuleTypeGroupMatch, e.g. only
+	// items with gender f in a cabin with gender f, or only wheelchair users in a van with wheelchair "yes". Groups
+	// without a value allow any item. Like Quotas, this is more important than any rule, regardless of the rule's
+	// Priority; the weight is the penalty for each item in a group it isn't eligible for.
+	RuleTypeGroupEligibility RuleType = "GroupEligibility"
+
+	// Interpret the tag value as the names of the groups the item would like to be in (a comma-separated list, best
+	// first), and try to put items in the groups they ranked highest. The points for each rank are set by the points
+	// param, e.g. "5|3|1"; by default the last of the longest list gets 1 point, and each better choice 1 more. The
+	// unranked param is the points for being in a group the item didn't choose, e.g. -2 for a penalty; by default 0.
+	// Items without choices don't count. See CountPreferences for how many items got each choice.
+	RuleTypeGroupPreference RuleType = "GroupPreference"
+)
+
+// Rule is one instance of an input rule. There could potentially be multiple rules on the same tag and/or of the same
+// type.
+type Rule struct {
+	// Tag name to which this rule applies
+	TagName string
+
+	// What type of rule this is (see RuleType)
+	Type RuleType
+
+	// How important this rule is relative to the other rules with the same Priority
+	Weight int
+
+	// Rules with a higher priority are optimized first, regardless of weight. Rules with a lower priority only break
+	// ties between arrangements that score the same on every higher priority.
+	Priority int
+
+	// Extra settings for rule types that need them, e.g. ones added with RegisterRuleType
+	Params map[string]string
+}
+
+// Group is passed to GetArrangement to indicate what groups there are and how full they can be.
+// Items will be populated by GetArrangement.
+type Group struct {
+	Name    string
+	MinSize int
+	MaxSize int
+	Items   []*Item
+
+	// Optional limits on how many items with particular tag values the group should have
+	Quotas []*Quota
+
+	// Optional map of tag names to tag values for this group, e.g. what the group is for or what it has, which rules
+	// like RuleTypeGroupMatch compare with the items' tags
+	Tags map[string]string
+}
+
+// Quota limits how many items with a particular tag value a group should have. Quotas are treated as more important
+// than any rule: an arrangement that falls short of or goes over fewer quotas is always preferred, regardless of rule
+// weights and priorities.
+type Quota struct {
+	TagName string
+	Value   string
+
+	// The least number of items with the tag value the group should have
+	Min int
+
+	// The most items with the tag value the group should have, or nil for no limit
+	Max *int
+}
+
+// digest produces a unique hash digest of the group, intended such that groups that are "equivalent" (e.g. regardless
+// of ordering, treating people with identical attributes as the same, etc.) have the same digest. Items are identified
+// by their class in classes (see initItemClasses), or by ID if they don't have one. The group's name only matters if
+// byName is true, but its sizes, quotas and tags always do.
+func (g *Group) digest(classes map[*Item]string, byName bool) stateDigest {
+	keys := make([]string, 0, len(g.Items))
+	for _, item := range g.Items {
+		keys = append(keys, itemClass(classes, item))
+	}
+	sort.Strings(keys)
+
+	h := fnv.New128a()
+	fmt.Fprintf(h, "%d-%d", g.MinSize, g.MaxSize)
+	if byName {
+		fmt.Fprintf(h, "|%q", g.Name)
+	}
+	for _, quota := range g.Quotas {
+		fmt.Fprintf(h, "|%q=%q:%d", quota.TagName, quota.Value, quota.Min)
+		if quota.Max != nil {
+			fmt.Fprintf(h, "-%d", *quota.Max)
+		}
+	}
+	tagNames := make([]string, 0, len(g.Tags))
+	for tagName := range g.Tags {
+		tagNames = append(tagNames, tagName)
+	}
+	sort.Strings(tagNames)
+	for _, tagName := range tagNames {
+		fmt.Fprintf(h, "|%q:%q", tagName, g.Tags[tagName])
+	}
+	h.Write([]byte{0})
+	for _, key := range keys {
+		// Terminate each key so that e.g. items "ab" and "c" don't hash the same as "a" and "bc"
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+	}
+	var d stateDigest
+	h.Sum(d[:0])
+	return d
+}
+
+// Copy creates a copy of a Group so it can be modified for a new State.
+// Note that Items are not deep copied as we don't modify these.
+func (g *Group) Copy() *Group {
+	newGroup := g.emptyCopy(len(g.Items))
+	newGroup.Items = append(newGroup.Items, g.Items...)
+	return newGroup
+}
+
+// emptyCopy creates a copy of a Group with no Items yet, but room for numItems of them.
+func (g *Group) emptyCopy(numItems int) *Group {
+	return &Group{
+		Name:    g.Name,
+		MinSize: g.MinSize,
+		MaxSize: g.MaxSize,
+		Items:   make([]*Item, 0, numItems),
+		Quotas:  g.Quotas,
+		Tags:    g.Tags,
+	}
+}
+
+// MustGetArrangement calls GetArrangement but panics on failures. Good for testing.
+func MustGetArrangement(items []*Item, rules []*Rule, groups []*Group) []*Group {
+	result, err := GetArrangement(context.Background(), items, rules, groups)
+	if err != nil {
+		panic(fmt.Sprintf("GetArrangement failed: %v", err))
+	}
+	return result
+}
+
+// GetArrangement is the primary workhorse of the algorithm. Given a set of items, rules, and groups to fill, it returns
+// copies of the Groups with Items filled in matching the rules. It is equivalent to Arrange with the default Options.
+func GetArrangement(ctx context.Context, items []*Item, rules []*Rule, groups []*Group) ([]*Group, error) {
+	result, err := Arrange(ctx, items, rules, groups, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Groups, nil
+}
+
+// State represents a particular arrangement of items in the groups, which may be intermediate/non-terminal. I.e. not
+// everyone has been placed in a group yet.
+type State struct {
+	// Groups which include items that may have been put in them in this state
+	Groups []*Group
+
+	// Items that haven't been put into groups yet for this state
+	ItemsNotInGroups []*Item
+
+	// Score is higher the better this State follows the provided rules.
+	// If this state is non-terminal (i.e. not all items are in groups yet), then Score is a heuristically guessed
+	// maximum score this state may end up producing after further iteration.
+	Score Score
+}
+
+// digest produces a unique hash digest of the state, intended such that states that are "equivalent" (e.g. regardless
+// of ordering, treating people with identical attributes as the same, etc.) have the same digest.
+func (s *State) digest(classes map[*Item]string, byName bool) stateDigest {
+	// First sort the digests, since we want the same digest regardless of the order of the groups
+	var digests []stateDigest
+	for _, group := range s.Groups {
+		digests = append(digests, group.digest(classes, byName))
+	}
+	sort.Slice(digests, func(i, j int) bool { return bytes.Compare(digests[i][:], digests[j][:]) < 0 })
+
+	h := fnv.New128a()
+	for _, d := range digests {
+		h.Write(d[:])
+	}
+	var d stateDigest
+	h.Sum(d[:0])
+	return d
+}
+
+// Copy produces a new State that can be modified without messing with the old State.
+// NOTE: Items themselves are not deep copied since we don't modify those.
+func (s *State) Copy() *State {
+	// Note: we don't have to copy the items themselves in these objects because we don't modify them
+	newState := &State{}
+	newState.ItemsNotInGroups = append(newState.ItemsNotInGroups, s.ItemsNotInGroups...)
+	newState.Groups = make([]*Group, 0, len(s.Groups))
+	for _, group := range s.Groups {
+		newState.Groups = append(newState.Groups, group.Copy())
+	}
+	return newState
+}
+
+// IsTerminal returns true if this state is a complete arrangement, every item has been put in a group.
+func (s *State) IsTerminal() bool {
+	return len(s.ItemsNotInGroups) == 0
+}
+
+// meetsMinSizes returns false if any group has items in it, but fewer than its MinSize.
+func (s *State) meetsMinSizes() bool {
+	for _, group := range s.Groups {
+		if len(group.Items) > 0 && len(group.Items) < group.MinSize {
+			return false
+		}
+	}
+	return true
+}
+
+//
+// The main algorithm runner
+//
+
+type runner struct {
+	// Stuff to be initialized with. Note that these slices should not be modified during the algorithm.
+	//
+	ctx    context.Context
+	items  []*Item
+	rules  []*Rule
+	groups []*Group
+
+	// Stuff created along the way:
+	//
+	// Best terminal state we've found along the way (cannot be a non-terminal state)
+	bestState   *State
+	statesToTry []*State
+
+	// The digests of the states that have been explored, and how many have been explored in total, including ones
+	// that visited has since forgotten
+	visited     *visitedStates
+	statesTried int
+
+	// The items that each item is always kept together with, see initBundles
+	bundleOf map[*Item][]*Item
+
+	// Which items are interchangeable, and whether groups are told apart by name, see initItemClasses
+	itemClasses map[*Item]string
+	namedGroups bool
+
+	// Pairs of items that are tried being moved together, see initLinkedPairs
+	linkedPairs [][2]*Item
+
+	// For state generation, the seedings to use in turn (see initSeedings), how many states have been made with them,
+	// and the random numbers used by them
+	seedings []Seeding
+	seedTurn int
+	rng      *rand.Rand
+
+	// For state generation, the current permutation of items we're trying
+	currentPermutation []int
+
+	// Solver settings the runner was created with
+	opts Options
+
+	// Maps each Rule.Priority to its index in a Score
+	tierByPriority map[int]int
+
+	// The scorers for each rule (and other scoring, like Options.RepeatWeight) that affects the score
+	scorers []tieredScorer
+
+	// When the search started, and how many times it has restarted from a new random state, for Options.Progress
+	start    time.Time
+	restarts int
+
+	// When a better state was last found, and how many restarts there have been since, for the stop conditions
+	lastImprovement            time.Time
+	restartsWithoutImprovement int
+
+	// The best score any arrangement could possibly have, see initBound
+	bound Score
+}
+
+func (r *runner) run() (*Result, error) {
+	if err := r.validateInput(); err != nil {
+		return nil, err
+	}
+
+	if err := r.initBundles(); err != nil {
+		return nil, err
+	}
+
+	r.initTiers()
+	if err := r.initScorers(); err != nil {
+		return nil, err
+	}
+	r.initItemClasses()
+	r.initLinkedPairs()
+
+	if r.opts.TargetScore != nil && len(r.opts.TargetScore) != len(r.tierByPriority) {
+		return nil, fmt.Errorf("bad configuration: the target score has %d tiers but the rules have %d",
+			len(r.opts.TargetScore), len(r.tierByPriority))
+	}
+	r.initBound()
+
+	if r.opts.Solver != "" && r.opts.Solver != SolverHillClimbing && r.opts.Solver != SolverTabu {
+		return nil, fmt.Errorf("bad configuration: unknown solver %q", r.opts.Solver)
+	}
+	if err := r.initSeedings(); err != nil {
+		return nil, err
+	}
+
+	r.start = time.Now()
+	r.lastImprovement = r.start
+	r.bestState = r.getWarmStartState()
+	if r.bestState == nil {
+		r.bestState = r.getStartingState()
+	}
+	if r.bestState == nil {
+		// Only possible with bundles, when the search is stopped before finding a way to fit them into the groups
+		return nil, fmt.Errorf("no arrangement that fits the bundles into the groups was found: %v", r.ctx.Err())
+	}
+	r.reportProgress()
+
+	var stopReason StopReason
+	if r.opts.Solver == SolverTabu {
+		stopReason = r.tabuSearch()
+	} else {
+		stopReason = r.hillClimb()
+	}
+
+	return &Result{
+		Groups:     r.bestState.Groups,
+		Score:      r.bestState.Score,
+		Breakdown:  r.breakdown(r.bestState.Groups),
+		StopReason: stopReason,
+	}, nil
+}
+
+// hillClimb is SolverHillClimbing. It starts from r.bestState and returns why it stopped.
+func (r *runner) hillClimb() StopReason {
+	next := r.bestState
+	for {
+		if stopReason := r.stopReason(); stopReason != "" {
+			return stopReason
+		}
+
+		digest := next.digest(r.itemClasses, r.namedGroups)
+		if r.visited.contains(digest) {
+			next = r.restart()
+			if next == nil {
+				return r.noStartingStateReason()
+			}
+			continue
+		}
+		r.visited.add(digest)
+		r.statesTried++
+
+		bestOption := r.getBestNextStateFrom(next)
+		if bestOption.Score.Better(next.Score) {
+			// Keep exploring starting from this new best state
+			next = bestOption
+			continue
+		}
+
+		r.improve(next)
+
+		// At this point we've explored `next` up to a local maximum score, now let's restart from a random spot and see
+		// if we find anything better
+		next = r.restart()
+		if next == nil {
+			return r.noStartingStateReason()
+		}
+	}
+}
+
+// improve makes s the best state if it's better than the best one so far.
+func (r *runner) improve(s *State) {
+	if s.Score.Better(r.bestState.Score) {
+		r.bestState = s
+		r.lastImprovement = time.Now()
+		r.restartsWithoutImprovement = 0
+		r.reportProgress()
+	}
+}
+
+// restart returns a new state to continue searching from, or nil if every state has been tried.
+func (r *runner) restart() *State {
+	r.restarts++
+	r.restartsWithoutImprovement++
+	return r.getStartingState()
+}
+
+// stopReason returns why the search should stop now, or "" if it should keep going.
+func (r *runner) stopReason() StopReason {
+	// Check if we've timed out. Will cause us to return the best state we have so far.
+	select {
+	case <-r.ctx.Done():
+		if r.ctx.Err() == context.DeadlineExceeded {
+			return StopReasonTimeout
+		}
+		return StopReasonCanceled
+	default:
+	}
+
+	best := r.bestState.Score
+	switch {
+	case r.bound.finite() && !r.bound.Better(best):
+		// A bound that isn't a number can't be reached, whatever Better says about it
+		return StopReasonOptimal
+	case r.opts.TargetScore != nil && !r.opts.TargetScore.Better(best):
+		return StopReasonTargetScore
+	case r.opts.MaxStates > 0 && r.statesTried >= r.opts.MaxStates:
+		return StopReasonMaxStates
+	case r.opts.StopAfterRestarts > 0 && r.restartsWithoutImprovement >= r.opts.StopAfterRestarts:
+		return StopReasonNoImprovement
+	case r.opts.StopAfterNoImprovement > 0 && time.Since(r.lastImprovement) >= r.opts.StopAfterNoImprovement:
+		return StopReasonNoImprovement
+	}
+	return ""
+}
+
+// initBound works out the best score any arrangement could have, from the rules' MaxPotentialScore for a state with
+// every item still to be placed. A search that reaches it can stop, since it can't do any better.
+func (r *runner) initBound() {
+	empty := &State{ItemsNotInGroups: r.items}
+	for _, group := range r.groups {
+		empty.Groups = append(empty.Groups, group.emptyCopy(0))
+	}
+	r.bound = r.CalculateMaxPotentialScore(empty)
+}
+
+// noStartingStateReason returns why there was no new state to start searching from: either every state has been tried,
+// or the search was stopped while looking for one.
+func (r *runner) noStartingStateReason() StopReason {
+	if stopReason := r.stopReason(); stopReason == StopReasonTimeout || stopReason == StopReasonCanceled {
+		return stopReason
+	}
+	return StopReasonExhausted
+}
+
+// getRandomState keeps returning different permutations of possible states.
+// It will never repeat the same state twice, and when it has exhausted all possible permutations, or the search is
+// stopped, it will return nil.
+// Items in a bundle (see initBundles) are permuted as one unit.
+func (r *runner) getRandomState() *State {
+	units := r.unitsIn(r.items)
+	for {
+		if r.currentPermutation == nil {
+			// On our first pass, use an empty permutation, which just means return the items in existing order
+			r.currentPermutation = make([]int, len(units))
+		} else {
+			// Increment to the next permutation
+			// For now this is a fisher-yates algorithm, as provided in https://stackoverflow.com/a/30230552
+			for i := len(r.currentPermutation) - 1; i >= 0; i-- {
+				if i == 0 || r.currentPermutation[i] < len(r.currentPermutation)-i-1 {
+					r.currentPermutation[i]++
+					break
+				}
+				r.currentPermutation[i] = 0
+			}
+
+			if r.currentPermutation[0] >= len(r.currentPermutation) {
+				// This indicates we've gone through every permutation
+				return nil
+			}
+		}
+
+		nextPerm := append([][]*Item{}, units...)
+		for i, v := range r.currentPermutation {
+			nextPerm[i], nextPerm[i+v] = nextPerm[i+v], nextPerm[i]
+		}
+		if s := r.scatterUnits(nextPerm); s != nil {
+			return s
+		}
+		// The bundles couldn't all be fitted into the groups in this order, so try the next one. There can be a great
+		// many of those in a row, so stop going through them once the search is over.
+		if r.ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// scatterUnits returns the state with the units spread evenly across the groups in order, or nil if that leaves a
+// bundle that doesn't fit in any group.
+func (r *runner) scatterUnits(units [][]*Item) *State {
+	s := &State{
+		Groups: make([]*Group, 0, len(r.groups)),
+	}
+	for _, group := range r.groups {
+		s.Groups = append(s.Groups, group.emptyCopy(len(r.items)/len(r.groups)))
+	}
+	if !placeUnits(s.Groups, units) {
+		return nil
+	}
+	s.Score = r.CalculateScore(s)
+
+	return s
+}
+
+// placeUnits adds the units to the groups in order, first up to their MinSizes and then round-robin up to their
+// MaxSizes. It returns false if that leaves a bundle that doesn't fit in any group.
+func placeUnits(groups []*Group, units [][]*Item) bool {
+	// First, ensure every group has at least MinSize number of items
+	i := 0
+	for _, group := range groups {
+		for i < len(units) && len(group.Items) < group.MinSize && len(group.Items)+len(units[i]) <= group.MaxSize {
+			group.Items = append(group.Items, units[i]...)
+			i++
+		}
+	}
+
+	// Now add people to groups round-robin
+	for i < len(units) {
+		placed := false
+		for _, group := range groups {
+			if len(group.Items)+len(units[i]) > group.MaxSize {
+				// This group is maxed, we can't try putting another in it
+				continue
+			}
+
+			group.Items = append(group.Items, units[i]...)
+			placed = true
+			i++
+			if i >= len(units) {
+				break
+			}
+		}
+		if !placed {
+			// Validation ensures there's room for everyone, but bundles can leave gaps that nothing else fits in
+			return false
+		}
+	}
+	return true
+}
+
+func (r *runner) validateInput() error {
+	var numSlots int
+	for _, group := range r.groups {
+		numSlots += group.MaxSize
+	}
+	if numSlots < len(r.items) {
+		return fmt.Errorf("bad configuration: there are %d items to arrange but only %d possible slots", len(r.items), numSlots)
+	}
+	for _, rule := range r.rules {
+		if rule.Priority >= constraintPriority {
+			return fmt.Errorf("bad configuration: %s rule for tag %q has priority %d, but priorities must be below %d",
+				rule.Type, rule.TagName, rule.Priority, constraintPriority)
+		}
+	}
+	if r.opts.RepeatPriority >= constraintPriority {
+		return fmt.Errorf("bad configuration: the repeat priority is %d, but priorities must be below %d",
+			r.opts.RepeatPriority, constraintPriority)
+	}
+	return nil
+}
+
+// getBestNextStateFrom returns the best state one move away from sourceState (see bestMove), or sourceState if none
+// are better. Only if no move or swap is better are the slower rotations tried (see bestRotation).
+func (r *runner) getBestNextStateFrom(sourceState *State) *State {
+	m := r.bestMove(sourceState, nil)
+	if m == nil || !m.score.Better(sourceState.Score) {
+		m = r.bestRotation(sourceState, nil)
+	}
+	if m == nil || !m.score.Better(sourceState.Score) {
+		return sourceState
+	}
+	return r.applyMove(sourceState, m)
+}
+
+// removeItems returns a new slice of the items, without the ones in toRemove.
+func removeItems(items, toRemove []*Item) []*Item {
+	kept := make([]*Item, 0, len(items))
+	for _, item := range items {
+		if !containsItem(toRemove, item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// insertStateToTry adds in the new state while maintaining that states is sorted from highest to lowest score
+func (r *runner) insertStateToTry(states []*State, toInsert *State) []*State {
+	i := sort.Search(len(states), func(i int) bool {
+		return toInsert.Score.Better(states[i].Score)
+	})
+	if i < len(states) {
+		// Insert the new state in the position it should be
+		states = append(states[:i], append([]*State{toInsert}, states[i:]...)...)
+	} else {
+		states = append(states, toInsert)
+	}
+	return states
+}
+
+func (r *runner) CalculateScore(s *State) Score {
+	// If a state is not terminal then calculate a heuristic rather than a real score
+	if !s.IsTerminal() {
+		return r.CalculateMaxPotentialScore(s)
+	}
+
+	// For terminal states, return the lowest possible score if it doesn't meet minimum group size constraints
+	if !s.meetsMinSizes() {
+		return r.worstScore()
+	}
+
+	return r.CalculateCurrentScore(s)
+}
+
+func (r *runner) CalculateCurrentScore(s *State) Score {
+	score := r.newScore()
+	for _, ts := range r.scorers {
+		for _, group := range s.Groups {
+			score[ts.tier] += ts.scorer.ScoreGroup(group)
+		}
+	}
+	return score
+}
+
+func (r *runner) CalculateMaxPotentialScore(s *State) Score {
+	maxScore := r.CalculateCurrentScore(s)
+	for _, ts := range r.scorers {
+		maxScore[ts.tier] += ts.scorer.MaxPotentialScore(s)
+	}
+	return maxScore
+}
+
+// Extra stuff
+//
+
+func factorial(n int) int {
+	if n <= 0 {
+		return 1
+	}
+
+	final := 1
+	for i := 1; i <= n; i++ {
+		final *= i
+	}
+	return final
+}
@@ -0,0 +1,437 @@
+package arrange
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+// newTestRunner returns a runner initialized the way a search would be, without starting one.
+func newTestRunner(t *testing.T, items []*Item, rules []*Rule, groups []*Group, opts Options) *runner {
+	r := newRunner(context.Background(), items, rules, groups, opts)
+	assert.Equal(t, nil, r.initBundles())
+	r.initTiers()
+	assert.Equal(t, nil, r.initScorers())
+	r.initItemClasses()
+	r.initLinkedPairs()
+	return r
+}
+
+// assertArrangementsEqual compares two arrangements ignoring data fields we don't care about and focusing on the items
+// being grouped properly. Ignoring sort orders and such.
+func assertArrangementsEqual(t *testing.T, exp, got []*Group) {
+	// Sort all the items in both groups, then the groups themselves, in a stable way for comparison
+	for _, groups := range [][]*Group{exp, got} {
+		for _, group := range groups {
+			sort.Slice(group.Items, func(i, j int) bool { return group.Items[i].ID < group.Items[j].ID })
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if len(groups[i].Items) != len(groups[j].Items) {
+				return len(groups[i].Items) < len(groups[j].Items)
+			}
+			if len(groups[i].Items) == 0 && len(groups[j].Items) == 0 {
+				return false
+			}
+			return groups[i].Items[0].ID < groups[j].Items[0].ID
+		})
+	}
+
+	for i := range exp {
+		var expItemIDs []string
+		for _, item := range exp[i].Items {
+			expItemIDs = append(expItemIDs, item.ID)
+		}
+		var gotItemIDs []string
+		for _, item := range got[i].Items {
+			gotItemIDs = append(gotItemIDs, item.ID)
+		}
+		assert.Equal(t, expItemIDs, gotItemIDs)
+	}
+}
+
+func TestBasic(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "girl2"}, &Item{ID: "girl1"}}},
+			&Group{Items: []*Item{&Item{ID: "guy2"}, &Item{ID: "guy1"}}},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 1, MaxSize: 2},
+				&Group{Name: "Group 2", MinSize: 1, MaxSize: 2},
+			}),
+	)
+}
+
+func TestWeightsWithSamenessGenderThenChurch(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{
+				Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "girl1"}, &Item{ID: "girl2"}},
+			},
+			&Group{
+				Items: []*Item{&Item{ID: "guy3"}, &Item{ID: "guy2"}, &Item{ID: "guy1"}},
+			},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c2"}},
+				&Item{ID: "guy3", Tags: map[string]string{"gender": "m", "church": "c2"}},
+				&Item{ID: "girl3", Tags: map[string]string{"gender": "f", "church": "c2"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2},
+				&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+				&Group{Name: "Group 2", MinSize: 1, MaxSize: 3},
+			}),
+	)
+}
+
+func TestWeightsWithSamenessChurchThenGender(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{
+				Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "guy3"}, &Item{ID: "girl2"}},
+			},
+			&Group{
+				Items: []*Item{&Item{ID: "guy2"}, &Item{ID: "girl1"}, &Item{ID: "guy1"}},
+			},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c2"}},
+				&Item{ID: "guy3", Tags: map[string]string{"gender": "m", "church": "c2"}},
+				&Item{ID: "girl3", Tags: map[string]string{"gender": "f", "church": "c2"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+				&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 2},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+				&Group{Name: "Group 2", MinSize: 1, MaxSize: 3},
+			}),
+	)
+}
+
+func TestNearness(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{
+				Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "guy3"}},
+			},
+			&Group{
+				Items: []*Item{&Item{ID: "girl2"}, &Item{ID: "girl1"}, &Item{ID: "guy2"}, &Item{ID: "guy1"}},
+			},
+		},
+		MustGetArrangement(
+			[]*Item{
+				// 38.831076, -77.194633 is Annandale
+				&Item{ID: "guy1", Tags: map[string]string{"location": "38.831076, -77.194633"}},
+				&Item{ID: "girl1", Tags: map[string]string{"location": "38.831076, -77.194633"}},
+				// 38.922574, -77.235782 is Tysons
+				&Item{ID: "guy2", Tags: map[string]string{"location": "38.922574, -77.235782"}},
+				&Item{ID: "girl2", Tags: map[string]string{"location": "38.922574, -77.235782"}},
+				// 38.667573, -77.255849 is Woodbridge
+				&Item{ID: "guy3", Tags: map[string]string{"location": "38.667573, -77.255849"}},
+				&Item{ID: "girl3", Tags: map[string]string{"location": "38.667573, -77.255849"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "location", Type: RuleTypeNearness, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 1, MaxSize: 4},
+				&Group{Name: "Group 2", MinSize: 1, MaxSize: 4},
+			}),
+	)
+}
+
+func TestRespectMinSize(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{
+				Name:  "Group 1",
+				Items: []*Item{&Item{ID: "guy1"}, &Item{ID: "girl1"}, &Item{ID: "guy2"}, &Item{ID: "girl2"}},
+			},
+			&Group{
+				Items: []*Item{},
+			},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 3, MaxSize: 4},
+				&Group{Name: "Group 2", MinSize: 3, MaxSize: 4},
+			}),
+	)
+}
+
+func TestPriorityOverridesWeight(t *testing.T) {
+	// Church has a much higher weight, but gender has a higher priority, so gender should be optimized first
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{
+				Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "girl1"}, &Item{ID: "girl2"}},
+			},
+			&Group{
+				Items: []*Item{&Item{ID: "guy3"}, &Item{ID: "guy2"}, &Item{ID: "guy1"}},
+			},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c2"}},
+				&Item{ID: "guy3", Tags: map[string]string{"gender": "m", "church": "c2"}},
+				&Item{ID: "girl3", Tags: map[string]string{"gender": "f", "church": "c2"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: 1},
+				&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 100},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+				&Group{Name: "Group 2", MinSize: 1, MaxSize: 3},
+			}),
+	)
+}
+
+func TestLowerPriorityBreaksTies(t *testing.T) {
+	// Both arrangements that keep genders together score the same on the gender tier, so church decides among them
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "girl1"}, &Item{ID: "girl2"}}},
+			&Group{Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "girl4"}}},
+			&Group{Items: []*Item{&Item{ID: "guy1"}, &Item{ID: "guy2"}}},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "girl3", Tags: map[string]string{"gender": "f", "church": "c2"}},
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "girl4", Tags: map[string]string{"gender": "f", "church": "c2"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c2"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: 2},
+				&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1, Priority: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+				&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+				&Group{Name: "Group 3", MinSize: 2, MaxSize: 2},
+			}),
+	)
+}
+
+func TestTiersOnlyForPrioritiesInUse(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+		&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+	}
+	rules := []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: 1}}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+	}
+
+	// Repeats aren't scored without a pair history, so they don't get a tier of their own
+	result, err := Arrange(context.Background(), items, rules, groups, Options{RepeatWeight: 1, TargetScore: Score{4}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(result.Score))
+
+	result, err = Arrange(context.Background(), items, rules, groups, Options{PairHistory: NewPairHistory(), RepeatWeight: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Score))
+
+	result, err = Arrange(context.Background(), items, nil, groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{0}, result.Score)
+}
+
+func TestPriorityMustBeBelowConstraints(t *testing.T) {
+	items := []*Item{&Item{ID: "guy1"}, &Item{ID: "girl1"}}
+	groups := []*Group{&Group{Name: "Group 1", MaxSize: 2}}
+	_, err := Arrange(context.Background(), items,
+		[]*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: constraintPriority}}, groups, Options{})
+	assert.Equal(t, `bad configuration: Sameness rule for tag "gender" has priority 2147483647, but priorities must be below 2147483647`, err.Error())
+}
+
+func TestArrangeReturnsScore(t *testing.T) {
+	result, err := Arrange(context.Background(),
+		[]*Item{
+			&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+			&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+		},
+		[]*Rule{
+			&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+		},
+		[]*Group{
+			&Group{Name: "Group 1", MinSize: 1, MaxSize: 2},
+			&Group{Name: "Group 2", MinSize: 1, MaxSize: 2},
+		},
+		Options{Timeout: time.Minute})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{8}, result.Score)
+}
+
+func TestArrangeReportsProgress(t *testing.T) {
+	var reports []Progress
+	result, err := Arrange(context.Background(),
+		[]*Item{
+			&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+			&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+		},
+		[]*Rule{
+			&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+		},
+		[]*Group{
+			&Group{Name: "Group 1", MinSize: 1, MaxSize: 2},
+			&Group{Name: "Group 2", MinSize: 1, MaxSize: 2},
+		},
+		Options{Timeout: time.Minute, Progress: func(p Progress) { reports = append(reports, p) }})
+	assert.Equal(t, nil, err)
+
+	// The starting state is reported, then each better one, ending with the result
+	assert.NotEqual(t, 0, len(reports))
+	for i := 1; i < len(reports); i++ {
+		assert.T(t, reports[i].Best.Score.Better(reports[i-1].Best.Score))
+		assert.T(t, reports[i].StatesTried > 0)
+	}
+	assert.Equal(t, result.Score, reports[len(reports)-1].Best.Score)
+}
+
+func TestArrangeStopReasons(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+		&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "guy3", Tags: map[string]string{"gender": "m"}},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+	}
+	together := []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1}}
+	apart := []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: -1}}
+
+	for _, tc := range []struct {
+		name  string
+		rules []*Rule
+		opts  Options
+		exp   StopReason
+	}{
+		// Two of the three guys can be together but the third can't be with them, so the bound of 2*3 + 1 can't be
+		// reached
+		{"exhausted", together, Options{}, StopReasonExhausted},
+		{"max states", together, Options{MaxStates: 1}, StopReasonMaxStates},
+		{"target score", together, Options{TargetScore: Score{6}}, StopReasonTargetScore},
+		{"no improvement", together, Options{StopAfterRestarts: 1}, StopReasonNoImprovement},
+		{"timeout", together, Options{Timeout: time.Nanosecond}, StopReasonTimeout},
+		// Keeping the guys as far apart as the group sizes allow reaches the bound
+		{"optimal apart", apart, Options{}, StopReasonOptimal},
+	} {
+		result, err := Arrange(context.Background(), items, tc.rules, groups, tc.opts)
+		assert.Equal(t, nil, err, tc.name)
+		assert.Equal(t, tc.exp, result.StopReason, tc.name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := Arrange(ctx, items, together, groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, StopReasonCanceled, result.StopReason)
+
+	_, err = Arrange(context.Background(), items, together, groups, Options{TargetScore: Score{6, 1}})
+	assert.Equal(t, "bad configuration: the target score has 2 tiers but the rules have 1", err.Error())
+}
+
+func TestArrangeStopsWhenOptimal(t *testing.T) {
+	result, err := Arrange(context.Background(),
+		[]*Item{
+			&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+			&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+		},
+		[]*Rule{
+			&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+		},
+		[]*Group{
+			&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+			&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+		},
+		Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{8}, result.Score)
+	assert.Equal(t, StopReasonOptimal, result.StopReason)
+}
+
+func TestNearnessWithIdenticalPoints(t *testing.T) {
+	// All the points are in one place, so every arrangement is as near as can be, and the search knows it
+	var items []*Item
+	for _, id := range []string{"guy1", "girl1", "guy2", "girl2"} {
+		items = append(items, &Item{ID: id, Tags: map[string]string{"location": "38.831076, -77.194633"}})
+	}
+	result, err := Arrange(context.Background(), items,
+		[]*Rule{
+			&Rule{TagName: "location", Type: RuleTypeNearness, Weight: 1},
+		},
+		[]*Group{
+			&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+			&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+		},
+		Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{4}, result.Score)
+	assert.Equal(t, StopReasonOptimal, result.StopReason)
+}
+
+func TestStopReasonIgnoresBoundsThatAreNotNumbers(t *testing.T) {
+	r := newRunner(context.Background(), nil, nil, nil, Options{})
+	r.bestState = &State{Score: Score{4, 2}}
+	for _, bound := range []Score{{math.NaN(), 0}, {4, math.Inf(1)}, {4, math.Inf(-1)}} {
+		r.bound = bound
+		assert.Equal(t, StopReason(""), r.stopReason(), bound)
+	}
+	r.bound = Score{4, 2}
+	assert.Equal(t, StopReasonOptimal, r.stopReason())
+}
@@ -0,0 +1,127 @@
+package arrange
+
+import (
+	"fmt"
+	"sort"
+)
+
+// initBundles works out the units that the search places and moves between groups: the items with the same value of
+// Options.BundleTag, like a family or the people in a carpool, are always kept together as one unit, and every other
+// item is a unit on its own.
+func (r *runner) initBundles() error {
+	r.bundleOf = nil
+	if r.opts.BundleTag == "" {
+		return nil
+	}
+
+	byValue := map[string][]*Item{}
+	var values []string
+	for _, item := range r.items {
+		val := item.Tags[r.opts.BundleTag]
+		if val == "" {
+			continue
+		}
+		if _, ok := byValue[val]; !ok {
+			values = append(values, val)
+		}
+		byValue[val] = append(byValue[val], item)
+	}
+
+	var maxSize int
+	for _, group := range r.groups {
+		if group.MaxSize > maxSize {
+			maxSize = group.MaxSize
+		}
+	}
+
+	r.bundleOf = map[*Item][]*Item{}
+	var sizes []int
+	for _, val := range values {
+		bundle := byValue[val]
+		if len(bundle) < 2 {
+			continue
+		}
+		if len(bundle) > maxSize {
+			return fmt.Errorf("bad configuration: bundle %q has %d items, but no group can hold more than %d",
+				val, len(bundle), maxSize)
+		}
+		for _, item := range bundle {
+			r.bundleOf[item] = bundle
+		}
+		sizes = append(sizes, len(bundle))
+	}
+
+	// Validation ensures there's room for every item, but bundles can leave gaps that nothing else fits in
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	room := make([]int, len(r.groups))
+	for i, group := range r.groups {
+		room[i] = group.MaxSize
+	}
+	if !bundlesFit(sizes, room, map[string]bool{}) {
+		return fmt.Errorf("bad configuration: the bundles can't all be fitted into the groups without going over a MaxSize")
+	}
+	return nil
+}
+
+// bundlesFit returns whether bundles of the given sizes, largest first, can all be put into groups with the given room
+// left in them. Groups with the same room left are interchangeable, so only one of them is tried for each bundle, and
+// the rooms that have been found not to work are remembered in failed.
+func bundlesFit(sizes, room []int, failed map[string]bool) bool {
+	if len(sizes) == 0 {
+		return true
+	}
+	sorted := append([]int{}, room...)
+	sort.Ints(sorted)
+	key := fmt.Sprint(len(sizes), sorted)
+	if failed[key] {
+		return false
+	}
+
+	tried := map[int]bool{}
+	for i, left := range room {
+		if left < sizes[0] || tried[left] {
+			continue
+		}
+		tried[left] = true
+		room[i] -= sizes[0]
+		fits := bundlesFit(sizes[1:], room, failed)
+		room[i] += sizes[0]
+		if fits {
+			return true
+		}
+	}
+	failed[key] = true
+	return false
+}
+
+// unitOf returns the items that are kept together with item, including itself.
+func (r *runner) unitOf(item *Item) []*Item {
+	if bundle, ok := r.bundleOf[item]; ok {
+		return bundle
+	}
+	return []*Item{item}
+}
+
+// unitsIn returns the units (see initBundles) that the items belong to, each once, in the order they're first found.
+func (r *runner) unitsIn(items []*Item) [][]*Item {
+	if len(r.bundleOf) == 0 {
+		// The common case, where every item is on its own
+		units := make([][]*Item, 0, len(items))
+		for i := range items {
+			units = append(units, items[i:i+1:i+1])
+		}
+		return units
+	}
+
+	var units [][]*Item
+	seen := map[*Item]bool{}
+	for _, item := range items {
+		unit := r.unitOf(item)
+		if seen[unit[0]] {
+			continue
+		}
+		seen[unit[0]] = true
+		units = append(units, unit)
+	}
+	return units
+}
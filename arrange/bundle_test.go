@@ -0,0 +1,125 @@
+package arrange
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestArrangeKeepsBundlesTogether(t *testing.T) {
+	// The families are mixed, so the gender rule would split them up if it could
+	var items []*Item
+	for i, family := range []string{"A", "A", "A", "B", "B", "", "", "", "", ""} {
+		items = append(items, &Item{ID: fmt.Sprintf("p%d", i), Tags: map[string]string{
+			"family": family,
+			"gender": []string{"m", "f"}[i%2],
+		}})
+	}
+	rules := []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 5, MaxSize: 5},
+		&Group{Name: "Group 2", MinSize: 5, MaxSize: 5},
+	}
+
+	for _, solver := range []Solver{SolverHillClimbing, SolverTabu} {
+		result, err := Arrange(context.Background(), items, rules, groups, Options{Solver: solver, BundleTag: "family"})
+		assert.Equal(t, nil, err)
+
+		familyGroup := map[string]string{}
+		for _, group := range result.Groups {
+			assert.Equal(t, 5, len(group.Items))
+			for _, item := range group.Items {
+				family := item.Tags["family"]
+				if family == "" {
+					continue
+				}
+				if name, ok := familyGroup[family]; ok {
+					assert.Equal(t, name, group.Name)
+				}
+				familyGroup[family] = group.Name
+			}
+		}
+		assert.Equal(t, 2, len(familyGroup))
+
+		// Family A has 2 m and 1 f, and family B 1 m and 1 f, so the best is to put them apart, with the m singles
+		// joining A and the f singles joining B
+		assert.Equal(t, Score{34}, result.Score, solver)
+	}
+}
+
+func TestBundleTooBig(t *testing.T) {
+	var items []*Item
+	for i := 0; i < 4; i++ {
+		items = append(items, &Item{ID: fmt.Sprintf("p%d", i), Tags: map[string]string{"family": "A"}})
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 1, MaxSize: 3},
+	}
+	_, err := Arrange(context.Background(), items, nil, groups, Options{BundleTag: "family"})
+	assert.Equal(t, `bad configuration: bundle "A" has 4 items, but no group can hold more than 3`, err.Error())
+}
+
+func TestBundlesDontFit(t *testing.T) {
+	bundles := func(numBundles, numSingles int) []*Item {
+		var items []*Item
+		for i := 0; i < numBundles; i++ {
+			for j := 0; j < 2; j++ {
+				items = append(items, &Item{ID: fmt.Sprintf("b%d-%d", i, j), Tags: map[string]string{"family": fmt.Sprint(i)}})
+			}
+		}
+		for i := 0; i < numSingles; i++ {
+			items = append(items, &Item{ID: fmt.Sprintf("s%d", i)})
+		}
+		return items
+	}
+	groups := func(n, maxSize int) []*Group {
+		var groups []*Group
+		for i := 0; i < n; i++ {
+			groups = append(groups, &Group{Name: fmt.Sprint(i), MaxSize: maxSize})
+		}
+		return groups
+	}
+
+	// There's room for every item, but only one bundle fits in each group
+	for _, tc := range []struct {
+		items  []*Item
+		groups []*Group
+	}{
+		{bundles(3, 0), groups(2, 3)},
+		{bundles(11, 8), groups(10, 3)},
+	} {
+		_, err := Arrange(context.Background(), tc.items, nil, tc.groups, Options{BundleTag: "family", Timeout: 2 * time.Second})
+		assert.Equal(t, "bad configuration: the bundles can't all be fitted into the groups without going over a MaxSize", err.Error())
+	}
+}
+
+func TestBundlesFit(t *testing.T) {
+	// Putting each bundle into the group with the most room left doesn't work here, but there is a way
+	assert.Equal(t, true, bundlesFit([]int{3, 3, 2, 2}, []int{6, 4}, map[string]bool{}))
+	assert.Equal(t, false, bundlesFit([]int{3, 3, 3}, []int{5, 4}, map[string]bool{}))
+	assert.Equal(t, false, bundlesFit([]int{2, 2, 2}, []int{3, 3}, map[string]bool{}))
+}
+
+func TestBundlesStopFittingWhenCanceled(t *testing.T) {
+	var items []*Item
+	for _, family := range []string{"a", "a", "b", "b", "c", "c", "c", "d", "d", "d"} {
+		items = append(items, &Item{ID: fmt.Sprint(len(items)), Tags: map[string]string{"family": family}})
+	}
+	groups := []*Group{&Group{Name: "Group 1", MaxSize: 6}, &Group{Name: "Group 2", MaxSize: 4}}
+
+	// The bundles in the order given don't fit, and the search is over before another order is tried
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Arrange(ctx, items, nil, groups, Options{BundleTag: "family"})
+	assert.Equal(t, "no arrangement that fits the bundles into the groups was found: context canceled", err.Error())
+
+	result, err := Arrange(context.Background(), items, nil, groups, Options{BundleTag: "family"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 6, len(result.Groups[0].Items))
+}
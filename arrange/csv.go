@@ -0,0 +1,768 @@
+package arrange
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// LoadError is a problem with one value (or row) of an input file.
+type LoadError struct {
+	// Name of the file the problem is in
+	File string
+
+	// Row number within the file, where the header is row 1. 0 if the problem isn't with a particular row.
+	Row int
+
+	// Name of the column the problem is in, if any
+	Column string
+
+	// Location of the problem within a structured (JSON or YAML) file, e.g. "items[2].id", if any
+	Path string
+
+	// The offending value, if any
+	Value string
+
+	// What is wrong
+	Message string
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Row != 0 {
+		fmt.Fprintf(&b, " row %d", e.Row)
+	}
+	if e.Column != "" {
+		fmt.Fprintf(&b, " column %q", e.Column)
+	}
+	if e.Path != "" {
+		fmt.Fprintf(&b, " at %s", e.Path)
+	}
+	b.WriteString(": ")
+	if e.Value != "" {
+		fmt.Fprintf(&b, "%q: ", e.Value)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// LoadErrors is every problem found while loading input. Loading carries on past problems so that they can all be
+// reported at once.
+type LoadErrors []*LoadError
+
+func (le LoadErrors) Error() string {
+	msgs := make([]string, 0, len(le))
+	for _, e := range le {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// err returns le as an error, or nil if there are no problems.
+func (le LoadErrors) err() error {
+	if len(le) == 0 {
+		return nil
+	}
+	return le
+}
+
+// CSVOptions changes how input files are read. The zero value reads comma-, semicolon- or tab-separated files with the
+// item ID in the first column.
+type CSVOptions struct {
+	// Comma is the field delimiter. If 0, it is whichever of comma, semicolon or tab appears most in the header row.
+	// Not used for workbooks.
+	Comma rune
+
+	// IDColumn is the name of the column of an items file with the item IDs. If empty, the first column is used.
+	IDColumn string
+
+	// IgnoreColumns are columns of an items file that aren't tags, e.g. contact details, and are left out.
+	IgnoreColumns []string
+
+	// TrimSpace removes whitespace from the start and end of every value
+	TrimSpace bool
+}
+
+// columnAliases are other names accepted for the columns of the input files, besides the name itself. Column names
+// are matched ignoring case, spaces, underscores and hyphens, so "tag name" is the same as "TagName".
+var columnAliases = map[string][]string{
+	"TagName":   {"Tag"},
+	"RuleType":  {"Type", "Rule"},
+	"Params":    {"Parameters"},
+	"GroupName": {"Group", "Name"},
+	"MinSize":   {"Min", "Minimum"},
+	"MaxSize":   {"Max", "Maximum", "Size", "Capacity"},
+	"ItemID":    {"Item", "ID"},
+	"Group":     {"GroupName"},
+	"Item":      {"ItemID", "ID"},
+}
+
+// normalizeColumn returns the form of a column name used to match it, see columnAliases.
+func normalizeColumn(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-', '\t':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// columnMatches returns whether the header column is the given column or one of its aliases.
+func columnMatches(header, column string) bool {
+	normalized := normalizeColumn(header)
+	if normalized == normalizeColumn(column) {
+		return true
+	}
+	for _, alias := range columnAliases[column] {
+		if normalized == normalizeColumn(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// records are the parsed contents of an input file: a header row followed by data rows.
+type records struct {
+	file   string
+	opts   CSVOptions
+	header []string
+
+	// Row number of the header, usually 1
+	headerRow int
+
+	rows []record
+}
+
+// record is one data row of an input file.
+type record struct {
+	// Row number within the file (see LoadError.Row)
+	row    int
+	values []string
+}
+
+// readCSVRecords reads all the records from a CSV file. Rows that don't have the same number of columns as the header
+// are reported and left out.
+func readCSVRecords(r io.Reader, name string, opts CSVOptions) (*records, LoadErrors) {
+	var errs LoadErrors
+	recs := &records{file: name, opts: opts, headerRow: 1}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return recs, LoadErrors{&LoadError{File: name, Message: err.Error()}}
+	}
+	data = decodeText(data)
+
+	lines := &lineCounter{data: data, atLineStart: true}
+	reader := csv.NewReader(lines)
+	reader.FieldsPerRecord = -1
+	reader.Comma = opts.Comma
+	if reader.Comma == 0 {
+		reader.Comma = detectDelimiter(data)
+	}
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				errs = append(errs, &LoadError{File: name, Row: parseErr.Line, Message: parseErr.Err.Error()})
+				continue
+			}
+			errs = append(errs, &LoadError{File: name, Message: err.Error()})
+			break
+		}
+		// The record ends on the last line read, and starts as many lines before that as it has line breaks in quotes
+		row := lines.lines
+		for _, value := range values {
+			row -= strings.Count(value, "\n")
+		}
+		recs.trimSpace(values)
+
+		if recs.header == nil {
+			recs.header = values
+			recs.headerRow = row
+			continue
+		}
+		if len(values) != len(recs.header) {
+			errs = append(errs, &LoadError{File: name, Row: row,
+				Message: fmt.Sprintf("has %d columns but the header has %d", len(values), len(recs.header))})
+			continue
+		}
+		recs.rows = append(recs.rows, record{row: row, values: values})
+	}
+
+	if recs.header == nil && len(errs) == 0 {
+		errs = append(errs, &LoadError{File: name, Message: "at least a header row is required"})
+	}
+	return recs, errs
+}
+
+// lineCounter hands data to a csv.Reader up to one line at a time and counts the lines. The reader only asks for more
+// when it needs it to finish a record, so after each record the last line counted is the one the record ended on.
+type lineCounter struct {
+	data        []byte
+	lines       int
+	atLineStart bool
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	if len(lc.data) == 0 {
+		return 0, io.EOF
+	}
+	n := bytes.IndexByte(lc.data, '\n') + 1
+	if n == 0 {
+		n = len(lc.data)
+	}
+	if n > len(p) {
+		n = len(p)
+	}
+	copy(p, lc.data[:n])
+	lc.data = lc.data[n:]
+	if lc.atLineStart {
+		lc.lines++
+	}
+	lc.atLineStart = p[n-1] == '\n'
+	return n, nil
+}
+
+// trimSpace trims the values in place if the TrimSpace option is set. Header values are always trimmed.
+func (recs *records) trimSpace(values []string) {
+	if recs.opts.TrimSpace || recs.header == nil {
+		for i, value := range values {
+			values[i] = strings.TrimSpace(value)
+		}
+	}
+}
+
+// decodeText converts text exported by spreadsheets to UTF-8 without a byte order mark. UTF-16 must start with a byte
+// order mark; other text that isn't valid UTF-8 is assumed to be Windows-1252, which is what Excel uses on Windows.
+func decodeText(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian)
+	case !utf8.Valid(data):
+		var b strings.Builder
+		for _, c := range data {
+			if c >= 0x80 && c < 0xA0 && windows1252[c-0x80] != 0 {
+				b.WriteRune(windows1252[c-0x80])
+			} else {
+				b.WriteRune(rune(c))
+			}
+		}
+		return []byte(b.String())
+	}
+	return data
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252 to the characters they stand for. The rest of the bytes are
+// the same as in Latin-1, and as Unicode code points.
+var windows1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// detectDelimiter returns whichever of comma, semicolon or tab appears most in the first line, outside quotes.
+func detectDelimiter(data []byte) rune {
+	counts := map[rune]int{}
+	inQuotes := false
+	for _, c := range string(data) {
+		if c == '"' {
+			inQuotes = !inQuotes
+		}
+		if c == '\n' && !inQuotes {
+			break
+		}
+		if !inQuotes {
+			counts[c]++
+		}
+	}
+
+	delimiter := ','
+	for _, d := range []rune{';', '\t'} {
+		if counts[d] > counts[delimiter] {
+			delimiter = d
+		}
+	}
+	return delimiter
+}
+
+// checkColumns renames the columns in the header that match one of known (see columnAliases) to that name, and reports
+// any column that doesn't match, any that is repeated, and any of required that is missing.
+func (recs *records) checkColumns(known []string, required []string) LoadErrors {
+	errs, _ := recs.matchColumns(known, required, false)
+	return errs
+}
+
+// checkColumnsWithTags is like checkColumns, but rather than reporting the columns that don't match any of known, it
+// returns them by index as tag names. Columns that are spelled nearly like one of known are still reported, since
+// they're more likely to be typos than tags.
+func (recs *records) checkColumnsWithTags(known []string, required []string) (LoadErrors, map[int]string) {
+	return recs.matchColumns(known, required, true)
+}
+
+func (recs *records) matchColumns(known []string, required []string, tags bool) (LoadErrors, map[int]string) {
+	if recs.header == nil {
+		// Missing the header entirely has already been reported
+		return nil, nil
+	}
+	var errs LoadErrors
+	tagColumns := map[int]string{}
+	for i, column := range recs.header {
+		var match string
+		for _, k := range known {
+			if columnMatches(column, k) {
+				match = k
+				break
+			}
+		}
+		if match == "" && tags {
+			if near := nearColumn(column, known); near != "" {
+				errs = append(errs, &LoadError{File: recs.file, Row: recs.headerRow, Column: column,
+					Message: fmt.Sprintf("unknown column, did you mean %s? Give it a different name if it's a tag", near)})
+				recs.header[i] = ""
+				continue
+			}
+			tagColumns[i] = column
+			// Don't let it be mistaken for a known column
+			recs.header[i] = ""
+			continue
+		}
+		if match == "" {
+			errs = append(errs, &LoadError{File: recs.file, Row: recs.headerRow, Column: column,
+				Message: fmt.Sprintf("unknown column, expected one of %s", strings.Join(known, ", "))})
+			continue
+		}
+		if containsString(recs.header[:i], match) {
+			errs = append(errs, &LoadError{File: recs.file, Row: recs.headerRow, Column: column,
+				Message: fmt.Sprintf("is the same column as %s", match)})
+			// Don't let it overwrite the first one
+			recs.header[i] = ""
+			continue
+		}
+		recs.header[i] = match
+	}
+	for _, column := range required {
+		if !containsString(recs.header, column) {
+			errs = append(errs, &LoadError{File: recs.file, Row: recs.headerRow, Column: column,
+				Message: "required column is missing"})
+		}
+	}
+	return errs, tagColumns
+}
+
+// nearColumn returns the one of known that column is spelled nearly like (see columnAliases), or "" if there isn't one.
+// Short names aren't compared, since other short words are often only a letter or two away from them.
+func nearColumn(column string, known []string) string {
+	normalized := normalizeColumn(column)
+	for _, k := range known {
+		for _, name := range append([]string{k}, columnAliases[k]...) {
+			name = normalizeColumn(name)
+			maxDistance := 2
+			switch {
+			case len(name) < 5:
+				continue
+			case len(name) < 7:
+				maxDistance = 1
+			}
+			if editDistance(normalized, name) <= maxDistance {
+				return k
+			}
+		}
+	}
+	return ""
+}
+
+// editDistance returns how many characters have to be inserted, deleted, replaced or swapped with the next one to turn
+// a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between the first i runes of a and the first j of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(minInt(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// findColumn returns the index of the header column matching column (see columnAliases), or -1.
+func (recs *records) findColumn(column string) int {
+	for i, header := range recs.header {
+		if columnMatches(header, column) {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadItemsCSV reads the items to arrange from CSV. The first column is the item ID and every other column is a tag.
+// name is used to identify the file in errors, which are LoadErrors.
+func ReadItemsCSV(r io.Reader, name string) ([]*Item, error) {
+	return CSVOptions{}.ReadItems(r, name)
+}
+
+// ReadItems is like ReadItemsCSV, but the ID column and which columns are tags can be changed by the options.
+func (opts CSVOptions) ReadItems(r io.Reader, name string) ([]*Item, error) {
+	recs, errs := readCSVRecords(r, name, opts)
+	items := readItems(recs, &errs)
+	return items, errs.err()
+}
+
+func readItems(recs *records, errs *LoadErrors) []*Item {
+	if len(recs.header) < 1 {
+		return nil
+	}
+
+	// Unless the ID column is given, the first column is assumed to be the ID
+	idIndex := 0
+	if recs.opts.IDColumn != "" {
+		idIndex = -1
+		for i, column := range recs.header {
+			if normalizeColumn(column) == normalizeColumn(recs.opts.IDColumn) {
+				idIndex = i
+				break
+			}
+		}
+		if idIndex < 0 {
+			*errs = append(*errs, &LoadError{File: recs.file, Row: recs.headerRow, Column: recs.opts.IDColumn,
+				Message: "ID column is missing"})
+			return nil
+		}
+	}
+	idColumn := recs.header[idIndex]
+
+	// The rest of the columns are tag names
+	tagColumns := map[int]string{}
+	for i, column := range recs.header {
+		if i != idIndex && !recs.ignored(column) {
+			tagColumns[i] = column
+		}
+	}
+
+	var items []*Item
+	rowByID := map[string]int{}
+	for _, rec := range recs.rows {
+		id := rec.values[idIndex]
+		if id == "" {
+			*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: idColumn, Message: "ID is empty"})
+			continue
+		}
+		if prevRow, ok := rowByID[id]; ok {
+			*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: idColumn, Value: id,
+				Message: fmt.Sprintf("ID is the same as row %d", prevRow)})
+			continue
+		}
+		rowByID[id] = rec.row
+
+		item := &Item{ID: id, Tags: map[string]string{}}
+		for i, tagName := range tagColumns {
+			item.Tags[tagName] = rec.values[i]
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// ignored returns whether the column is one of the IgnoreColumns option.
+func (recs *records) ignored(column string) bool {
+	for _, ignored := range recs.opts.IgnoreColumns {
+		if normalizeColumn(column) == normalizeColumn(ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadRulesCSV reads rules from CSV with the columns TagName, RuleType and Weight, and optionally Priority and Params.
+// Params are written as "key1=value1;key2=value2". name is used to identify the file in errors, which are LoadErrors.
+func ReadRulesCSV(r io.Reader, name string) ([]*Rule, error) {
+	return CSVOptions{}.ReadRules(r, name)
+}
+
+// ReadRules is like ReadRulesCSV, with the options.
+func (opts CSVOptions) ReadRules(r io.Reader, name string) ([]*Rule, error) {
+	recs, errs := readCSVRecords(r, name, opts)
+	rules := readRules(recs, &errs)
+	return rules, errs.err()
+}
+
+func readRules(recs *records, errs *LoadErrors) []*Rule {
+	*errs = append(*errs, recs.checkColumns(
+		[]string{"TagName", "RuleType", "Weight", "Priority", "Params"},
+		[]string{"TagName", "RuleType", "Weight"})...)
+
+	var rules []*Rule
+	for _, rec := range recs.rows {
+		rule := &Rule{}
+		for i, columnValue := range rec.values {
+			column := recs.header[i]
+			switch column {
+			case "TagName":
+				rule.TagName = columnValue
+			case "RuleType":
+				rule.Type = RuleType(columnValue)
+				if _, ok := getRuleScorerFactory(rule.Type); !ok {
+					*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: column, Value: columnValue,
+						Message: fmt.Sprintf("unknown rule type, expected one of %s", ruleTypeList())})
+				}
+			case "Weight":
+				rule.Weight = recs.parseInt(rec, column, columnValue, true, errs)
+			case "Priority":
+				rule.Priority = recs.parseInt(rec, column, columnValue, false, errs)
+				if rule.Priority >= constraintPriority {
+					*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: column, Value: columnValue,
+						Message: fmt.Sprintf("must be below %d", constraintPriority)})
+				}
+			case "Params":
+				rule.Params = ParseParams(columnValue)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func ruleTypeList() string {
+	var names []string
+	for _, ruleType := range RuleTypes() {
+		names = append(names, string(ruleType))
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseParams parses rule parameters written as "key1=value1;key2=value2".
+func ParseParams(str string) map[string]string {
+	params := map[string]string{}
+	for _, param := range strings.Split(str, ";") {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) == 2 {
+			params[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		} else {
+			params[strings.TrimSpace(parts[0])] = ""
+		}
+	}
+	return params
+}
+
+// ReadGroupsCSV reads groups from CSV with the columns GroupName and MaxSize, and optionally MinSize. Any other
+// columns are the groups' tags. name is used to identify the file in errors, which are LoadErrors.
+func ReadGroupsCSV(r io.Reader, name string) ([]*Group, error) {
+	return CSVOptions{}.ReadGroups(r, name)
+}
+
+// ReadGroups is like ReadGroupsCSV, with the options.
+func (opts CSVOptions) ReadGroups(r io.Reader, name string) ([]*Group, error) {
+	recs, errs := readCSVRecords(r, name, opts)
+	groups := readGroups(recs, &errs)
+	return groups, errs.err()
+}
+
+func readGroups(recs *records, errs *LoadErrors) []*Group {
+	columnErrs, tagColumns := recs.checkColumnsWithTags(
+		[]string{"GroupName", "MinSize", "MaxSize"},
+		[]string{"GroupName", "MaxSize"})
+	*errs = append(*errs, columnErrs...)
+
+	var groups []*Group
+	for _, rec := range recs.rows {
+		group := &Group{}
+		for i, columnValue := range rec.values {
+			if tagName, ok := tagColumns[i]; ok {
+				if group.Tags == nil {
+					group.Tags = map[string]string{}
+				}
+				group.Tags[tagName] = columnValue
+				continue
+			}
+			column := recs.header[i]
+			switch column {
+			case "GroupName":
+				group.Name = columnValue
+			case "MinSize":
+				group.MinSize = recs.parseInt(rec, column, columnValue, false, errs)
+			case "MaxSize":
+				group.MaxSize = recs.parseInt(rec, column, columnValue, true, errs)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// ReadPairHistoryCSV reads the groups of previous rounds, with one row per item per round, from CSV with the columns
+// Round, GroupName and ItemID. Items sharing the same Round and GroupName were together.
+// name is used to identify the file in errors, which are LoadErrors.
+func ReadPairHistoryCSV(r io.Reader, name string) (*PairHistory, error) {
+	return CSVOptions{}.ReadPairHistory(r, name)
+}
+
+// ReadPairHistory is like ReadPairHistoryCSV, with the options.
+func (opts CSVOptions) ReadPairHistory(r io.Reader, name string) (*PairHistory, error) {
+	recs, errs := readCSVRecords(r, name, opts)
+	history := readPairHistory(recs, &errs)
+	return history, errs.err()
+}
+
+func readPairHistory(recs *records, errs *LoadErrors) *PairHistory {
+	*errs = append(*errs, recs.checkColumns(
+		[]string{"Round", "GroupName", "ItemID"},
+		[]string{"Round", "GroupName", "ItemID"})...)
+
+	type roundGroup struct {
+		round string
+		group string
+	}
+	var order []roundGroup
+	itemsByRoundGroup := map[roundGroup][]*Item{}
+	for _, rec := range recs.rows {
+		var key roundGroup
+		var itemID string
+		for i, columnValue := range rec.values {
+			switch recs.header[i] {
+			case "Round":
+				key.round = columnValue
+			case "GroupName":
+				key.group = columnValue
+			case "ItemID":
+				itemID = columnValue
+			}
+		}
+		if _, ok := itemsByRoundGroup[key]; !ok {
+			order = append(order, key)
+		}
+		itemsByRoundGroup[key] = append(itemsByRoundGroup[key], &Item{ID: itemID})
+	}
+
+	history := NewPairHistory()
+	for _, key := range order {
+		history.AddGroup(itemsByRoundGroup[key])
+	}
+	return history
+}
+
+// parseInt parses an integer value, reporting it if it isn't valid. Empty values are 0 unless required.
+func (recs *records) parseInt(rec record, column, value string, required bool, errs *LoadErrors) int {
+	if value == "" {
+		if required {
+			*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: column, Message: "is required"})
+		}
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: column, Value: value,
+			Message: "is not a whole number"})
+	}
+	return n
+}
+
+// ReadArrangementCSV reads an arrangement, like the CSV output of arrangeit, with one row per item. The Group column
+// is the name of the group the item is in, the Item column is its ID, and every other column is a tag. Groups are
+// returned in the order they first appear, with only their Name and Items set.
+// name is used to identify the file in errors, which are LoadErrors.
+func ReadArrangementCSV(r io.Reader, name string) ([]*Group, error) {
+	return CSVOptions{}.ReadArrangement(r, name)
+}
+
+// ReadArrangement is like ReadArrangementCSV, with the options. IgnoreColumns are left out of the items' tags.
+func (opts CSVOptions) ReadArrangement(r io.Reader, name string) ([]*Group, error) {
+	recs, errs := readCSVRecords(r, name, opts)
+	groups := readArrangement(recs, &errs)
+	return groups, errs.err()
+}
+
+func readArrangement(recs *records, errs *LoadErrors) []*Group {
+	if recs.header == nil {
+		return nil
+	}
+	groupColumn, itemColumn := recs.findColumn("Group"), recs.findColumn("Item")
+	if groupColumn < 0 || itemColumn < 0 {
+		*errs = append(*errs, &LoadError{File: recs.file, Row: recs.headerRow,
+			Message: "the Group and Item columns are required"})
+		return nil
+	}
+
+	var groups []*Group
+	groupsByName := map[string]*Group{}
+	rowByID := map[string]int{}
+	for _, rec := range recs.rows {
+		id := rec.values[itemColumn]
+		if id == "" {
+			// Blank rows may separate the groups
+			continue
+		}
+		if prevRow, ok := rowByID[id]; ok {
+			*errs = append(*errs, &LoadError{File: recs.file, Row: rec.row, Column: "Item", Value: id,
+				Message: fmt.Sprintf("ID is the same as row %d", prevRow)})
+			continue
+		}
+		rowByID[id] = rec.row
+
+		item := &Item{ID: id, Tags: map[string]string{}}
+		for i, columnValue := range rec.values {
+			if i != groupColumn && i != itemColumn && !recs.ignored(recs.header[i]) {
+				item.Tags[recs.header[i]] = columnValue
+			}
+		}
+
+		groupName := rec.values[groupColumn]
+		group, ok := groupsByName[groupName]
+		if !ok {
+			group = &Group{Name: groupName}
+			groupsByName[groupName] = group
+			groups = append(groups, group)
+		}
+		group.Items = append(group.Items, item)
+	}
+	return groups
+}
@@ -0,0 +1,183 @@
+package arrange
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReadItemsCSV(t *testing.T) {
+	items, err := ReadItemsCSV(strings.NewReader("Name,gender,age\nbob,m,15\nsue,f,16\n"), "items.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Item{
+		&Item{ID: "bob", Tags: map[string]string{"gender": "m", "age": "15"}},
+		&Item{ID: "sue", Tags: map[string]string{"gender": "f", "age": "16"}},
+	}, items)
+}
+
+func TestReadItemsCSVReportsEveryProblem(t *testing.T) {
+	_, err := ReadItemsCSV(strings.NewReader("Name,gender\nbob,m\nsue,f,extra\n,f\nbob,m\n"), "items.csv")
+	assert.Equal(t, strings.Join([]string{
+		`items.csv row 3: has 3 columns but the header has 2`,
+		`items.csv row 4 column "Name": ID is empty`,
+		`items.csv row 5 column "Name": "bob": ID is the same as row 2`,
+	}, "\n"), err.Error())
+}
+
+func TestReadItemsCSVRowNumbers(t *testing.T) {
+	// Blank lines and line breaks in quotes are counted, so the rows match what a text editor shows
+	_, err := ReadItemsCSV(strings.NewReader("Name,notes\r\n\r\nbob,\"two\r\nlines\"\r\n,\r\nbob,x"), "items.csv")
+	assert.Equal(t, strings.Join([]string{
+		`items.csv row 5 column "Name": ID is empty`,
+		`items.csv row 6 column "Name": "bob": ID is the same as row 3`,
+	}, "\n"), err.Error())
+}
+
+func TestReadRulesCSV(t *testing.T) {
+	rules, err := ReadRulesCSV(strings.NewReader(
+		"TagName,RuleType,Weight,Priority,Params\ngender,Sameness,2,,\nluggage,Sameness,1,1,limit=4;other\n"),
+		"rules.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2, Params: map[string]string{}},
+		&Rule{TagName: "luggage", Type: RuleTypeSameness, Weight: 1, Priority: 1,
+			Params: map[string]string{"limit": "4", "other": ""}},
+	}, rules)
+}
+
+func TestReadRulesCSVReportsEveryProblem(t *testing.T) {
+	_, err := ReadRulesCSV(strings.NewReader(
+		"TagName,RuleType,Weight,Wieght,Priority\ngender,Sameness,heavy,,\nage,Samenes,1,,\nchurch,Sameness,,,\nhome,Nearness,1,,3000000000\n"), "rules.csv")
+	errs := err.(LoadErrors)
+	assert.Equal(t, 5, len(errs))
+	assert.Equal(t, `rules.csv row 1 column "Wieght": unknown column, expected one of TagName, RuleType, Weight, Priority, Params`, errs[0].Error())
+	assert.Equal(t, &LoadError{File: "rules.csv", Row: 2, Column: "Weight", Value: "heavy", Message: "is not a whole number"}, errs[1])
+	assert.Equal(t, 3, errs[2].Row)
+	assert.Equal(t, "Samenes", errs[2].Value)
+	assert.Equal(t, `rules.csv row 4 column "Weight": is required`, errs[3].Error())
+	assert.Equal(t, `rules.csv row 5 column "Priority": "3000000000": must be below 2147483647`, errs[4].Error())
+}
+
+func TestReadGroupsCSV(t *testing.T) {
+	groups, err := ReadGroupsCSV(strings.NewReader("GroupName,MinSize,MaxSize\nVan,,7\nCar,2,4\n"), "groups.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", MaxSize: 7},
+		&Group{Name: "Car", MinSize: 2, MaxSize: 4},
+	}, groups)
+
+	// Other columns are tags
+	groups, err = ReadGroupsCSV(strings.NewReader("GroupName,MaxSize,wheelchair,language\nVan,7,yes,\"es,en\"\n"), "groups.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", MaxSize: 7, Tags: map[string]string{"wheelchair": "yes", "language": "es,en"}},
+	}, groups)
+
+	// Columns spelled nearly like the known ones aren't taken to be tags
+	groups, err = ReadGroupsCSV(strings.NewReader("Group Name,Minimum,Maximum,Grade\nVan,2,7,5\n"), "groups.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", MinSize: 2, MaxSize: 7, Tags: map[string]string{"Grade": "5"}},
+	}, groups)
+	_, err = ReadGroupsCSV(strings.NewReader("GroupName,MinSzie,Capcity,MaxSize\nVan,2,7,7\n"), "groups.csv")
+	assert.Equal(t, strings.Join([]string{
+		`groups.csv row 1 column "MinSzie": unknown column, did you mean MinSize? Give it a different name if it's a tag`,
+		`groups.csv row 1 column "Capcity": unknown column, did you mean MaxSize? Give it a different name if it's a tag`,
+	}, "\n"), err.Error())
+
+	_, err = ReadGroupsCSV(strings.NewReader("GroupName,MinSize\nVan,2.5\n"), "groups.csv")
+	assert.Equal(t, strings.Join([]string{
+		`groups.csv row 1 column "MaxSize": required column is missing`,
+		`groups.csv row 2 column "MinSize": "2.5": is not a whole number`,
+	}, "\n"), err.Error())
+
+	_, err = ReadGroupsCSV(strings.NewReader(""), "groups.csv")
+	assert.Equal(t, `groups.csv: at least a header row is required`, err.Error())
+}
+
+func TestReadPairHistoryCSV(t *testing.T) {
+	history, err := ReadPairHistoryCSV(strings.NewReader(
+		"Round,GroupName,ItemID\n1,A,bob\n1,A,sue\n1,B,joe\n2,A,bob\n2,A,sue\n2,A,joe\n"), "history.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, history.Count("bob", "sue"))
+	assert.Equal(t, 1, history.Count("joe", "sue"))
+}
+
+func TestReadArrangementCSV(t *testing.T) {
+	groups, err := ReadArrangementCSV(strings.NewReader(
+		"Group,Item,gender\nVan,bob,m\nVan,sue,f\n,,\nCar,joe,m\n"), "arrangement.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", Items: []*Item{
+			&Item{ID: "bob", Tags: map[string]string{"gender": "m"}},
+			&Item{ID: "sue", Tags: map[string]string{"gender": "f"}},
+		}},
+		&Group{Name: "Car", Items: []*Item{
+			&Item{ID: "joe", Tags: map[string]string{"gender": "m"}},
+		}},
+	}, groups)
+
+	_, err = ReadArrangementCSV(strings.NewReader("Group,Name\nVan,bob\n"), "arrangement.csv")
+	assert.Equal(t, `arrangement.csv row 1: the Group and Item columns are required`, err.Error())
+
+	_, err = ReadArrangementCSV(strings.NewReader("Group,Item\nVan,bob\nCar,bob\n"), "arrangement.csv")
+	assert.Equal(t, `arrangement.csv row 3 column "Item": "bob": ID is the same as row 2`, err.Error())
+}
+
+func TestScoreArrangement(t *testing.T) {
+	groups, err := ReadArrangementCSV(strings.NewReader(
+		"Group,Item,gender\nVan,bob,m\nVan,sue,f\nCar,joe,m\nCar,tom,m\n"), "arrangement.csv")
+	assert.Equal(t, nil, err)
+	for _, group := range groups {
+		group.MaxSize = 2
+	}
+	rule := &Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1}
+
+	score, breakdown, err := ScoreArrangement([]*Rule{rule}, groups, Options{})
+	assert.Equal(t, nil, err)
+	// 1² + 1² for the Van, 2² for the Car
+	assert.Equal(t, Score{6}, score)
+	assert.Equal(t, []RuleScore{{Rule: rule, Name: "Sameness on gender", Score: 6}}, breakdown)
+}
+
+func TestCSVOptions(t *testing.T) {
+	opts := CSVOptions{IDColumn: "name", IgnoreColumns: []string{"Phone Number"}, TrimSpace: true}
+	items, err := opts.ReadItems(strings.NewReader(
+		"\xEF\xBB\xBFphone_number; Name ;gender\n555-1234; bob ; m\n555-9876;sue;f\n"), "items.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Item{
+		&Item{ID: "bob", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "sue", Tags: map[string]string{"gender": "f"}},
+	}, items)
+
+	_, err = CSVOptions{IDColumn: "ID"}.ReadItems(strings.NewReader("Name,gender\nbob,m\n"), "items.csv")
+	assert.Equal(t, `items.csv row 1 column "ID": ID column is missing`, err.Error())
+}
+
+func TestReadCSVHeaderAliases(t *testing.T) {
+	rules, err := ReadRulesCSV(strings.NewReader("tag\ttype\tweight\ngender\tSameness\t2\n"), "rules.tsv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2}}, rules)
+
+	groups, err := ReadGroupsCSV(strings.NewReader("Group Name,min_size,Capacity\nVan,1,7\n"), "groups.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{&Group{Name: "Van", MinSize: 1, MaxSize: 7}}, groups)
+
+	_, err = ReadGroupsCSV(strings.NewReader("Group,Name,MaxSize\nVan,Car,7\n"), "groups.csv")
+	assert.Equal(t, `groups.csv row 1 column "Name": is the same column as GroupName`, err.Error())
+}
+
+func TestReadCSVEncodings(t *testing.T) {
+	utf16LE := []byte{0xFF, 0xFE}
+	for _, c := range "Name,city\nzoë,Köln\n" {
+		utf16LE = append(utf16LE, byte(c), byte(c>>8))
+	}
+	windows1252 := []byte("Name,city\nzo\xEB,K\xF6ln\n")
+
+	for _, data := range [][]byte{utf16LE, windows1252} {
+		items, err := ReadItemsCSV(strings.NewReader(string(data)), "items.csv")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []*Item{&Item{ID: "zoë", Tags: map[string]string{"city": "Köln"}}}, items)
+	}
+}
@@ -0,0 +1,141 @@
+package arrange
+
+import "fmt"
+
+// groupMatcher works out which items match which groups, for RuleTypeGroupMatch and RuleTypeGroupEligibility. An item
+// matches a group if any of the values of its tag is one of the values of the group's tag. The group's tag has the
+// same name as the rule's, unless the rule has a groupTag param.
+type groupMatcher struct {
+	rule     *Rule
+	groupTag string
+
+	// Each item's values of the tag. Items without a value are left out.
+	values map[*Item][]string
+}
+
+func newGroupMatcher(rule *Rule, items []*Item) groupMatcher {
+	gm := groupMatcher{rule: rule, groupTag: rule.Params["groupTag"], values: map[*Item][]string{}}
+	if gm.groupTag == "" {
+		gm.groupTag = rule.TagName
+	}
+	for _, item := range items {
+		if values := splitList(item.Tags[rule.TagName]); len(values) > 0 {
+			gm.values[item] = values
+		}
+	}
+	return gm
+}
+
+// groupValues returns the group's values of its tag, or nil if it has none, in which case the rule doesn't apply to it.
+func (gm groupMatcher) groupValues(group *Group) []string {
+	return splitList(group.Tags[gm.groupTag])
+}
+
+// countMatches returns how many of the items match the group values.
+func (gm groupMatcher) countMatches(groupValues []string, items []*Item) int {
+	var count int
+	for _, item := range items {
+		if gm.matches(groupValues, item) {
+			count++
+		}
+	}
+	return count
+}
+
+func (gm groupMatcher) matches(groupValues []string, item *Item) bool {
+	for _, val := range gm.values[item] {
+		if containsString(groupValues, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupMatchScorer implements RuleTypeGroupMatch. The rule's weight is scored for each item in a group that it matches.
+type groupMatchScorer struct {
+	groupMatcher
+}
+
+func newGroupMatchScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	return &groupMatchScorer{newGroupMatcher(rule, items)}, nil
+}
+
+func (gs *groupMatchScorer) ScoreGroup(group *Group) float64 {
+	groupValues := gs.groupValues(group)
+	if groupValues == nil {
+		return 0
+	}
+	return float64(gs.rule.Weight * gs.countMatches(groupValues, group.Items))
+}
+
+func (gs *groupMatchScorer) ScoreGroupDelta(group *Group, added, removed []*Item) float64 {
+	groupValues := gs.groupValues(group)
+	if groupValues == nil {
+		return 0
+	}
+	return float64(gs.rule.Weight * (gs.countMatches(groupValues, added) - gs.countMatches(groupValues, removed)))
+}
+
+func (gs *groupMatchScorer) MaxPotentialScore(s *State) float64 {
+	// If the rule weight is negative, the best we could do is keep every item out of the groups it matches
+	if gs.rule.Weight < 0 {
+		return 0
+	}
+
+	// At best, every item still to be placed that matches some group goes in one, as far as they have room
+	var room int
+	var valuesByGroup [][]string
+	for _, group := range s.Groups {
+		if groupValues := gs.groupValues(group); groupValues != nil {
+			room += group.MaxSize - len(group.Items)
+			valuesByGroup = append(valuesByGroup, groupValues)
+		}
+	}
+	var matching int
+	for _, item := range s.ItemsNotInGroups {
+		for _, groupValues := range valuesByGroup {
+			if gs.matches(groupValues, item) {
+				matching++
+				break
+			}
+		}
+	}
+	return float64(gs.rule.Weight * minInt(matching, room))
+}
+
+// groupEligibilityScorer implements RuleTypeGroupEligibility. The rule's weight is taken off for each item in a group
+// with a value of the tag that the item doesn't match.
+type groupEligibilityScorer struct {
+	groupMatcher
+}
+
+func newGroupEligibilityScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	if rule.Weight < 0 {
+		return nil, fmt.Errorf("the weight is the penalty for each item in a group it isn't eligible for, so it can't be negative")
+	}
+	return &groupEligibilityScorer{newGroupMatcher(rule, items)}, nil
+}
+
+func (es *groupEligibilityScorer) ScoreGroup(group *Group) float64 {
+	groupValues := es.groupValues(group)
+	if groupValues == nil {
+		return 0
+	}
+	ineligible := len(group.Items) - es.countMatches(groupValues, group.Items)
+	return -float64(es.rule.Weight * ineligible)
+}
+
+func (es *groupEligibilityScorer) ScoreGroupDelta(group *Group, added, removed []*Item) float64 {
+	groupValues := es.groupValues(group)
+	if groupValues == nil {
+		return 0
+	}
+	addedIneligible := len(added) - es.countMatches(groupValues, added)
+	removedIneligible := len(removed) - es.countMatches(groupValues, removed)
+	return -float64(es.rule.Weight * (addedIneligible - removedIneligible))
+}
+
+func (es *groupEligibilityScorer) MaxPotentialScore(s *State) float64 {
+	// Placing more items can only add ineligible ones
+	return 0
+}
@@ -0,0 +1,74 @@
+package arrange
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestGroupMatchAndEligibility(t *testing.T) {
+	var items []*Item
+	for i := 0; i < 6; i++ {
+		items = append(items, &Item{ID: fmt.Sprintf("p%d", i), Tags: map[string]string{
+			"gender":   []string{"m", "f"}[i%2],
+			"language": []string{"en", "es", "en,es"}[i%3],
+		}})
+	}
+	groups := []*Group{
+		&Group{Name: "Cabin A", MinSize: 3, MaxSize: 3, Tags: map[string]string{"gender": "f"}},
+		&Group{Name: "Cabin B", MinSize: 3, MaxSize: 3, Tags: map[string]string{"cabinLanguage": "es"}},
+	}
+	rules := []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeGroupEligibility, Weight: 1},
+		&Rule{TagName: "language", Type: RuleTypeGroupMatch, Weight: 1, Params: map[string]string{"groupTag": "cabinLanguage"}},
+	}
+
+	result, err := Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, nil, err)
+	for _, item := range result.Groups[0].Items {
+		assert.Equal(t, "f", item.Tags["gender"])
+	}
+	// The m items all have to go in Cabin B, whether they speak Spanish or not: p0 (en), p2 (en,es) and p4 (es)
+	assert.Equal(t, Score{0, 2}, result.Score)
+
+	// Without eligibility, the Spanish speakers all go in Cabin B
+	result, err = Arrange(context.Background(), items, rules[1:], groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{3}, result.Score)
+	for _, item := range result.Groups[1].Items {
+		assert.T(t, item.Tags["language"] != "en")
+	}
+}
+
+func TestGroupMatchMaxPotentialScore(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "a", Tags: map[string]string{"language": "es"}},
+		&Item{ID: "b", Tags: map[string]string{"language": "es"}},
+		&Item{ID: "c", Tags: map[string]string{"language": "fr,es"}},
+		&Item{ID: "d", Tags: map[string]string{"language": "en"}},
+	}
+	rule := &Rule{TagName: "language", Type: RuleTypeGroupMatch, Weight: 2}
+	scorer, err := newGroupMatchScorer(rule, items)
+	assert.Equal(t, nil, err)
+
+	// Only two of the three Spanish speakers fit in the Spanish group
+	s := &State{
+		Groups: []*Group{
+			&Group{MaxSize: 2, Tags: map[string]string{"language": "es"}},
+			&Group{MaxSize: 2},
+		},
+		ItemsNotInGroups: items,
+	}
+	assert.Equal(t, 4.0, scorer.MaxPotentialScore(s))
+
+	s.Groups[0].Items = []*Item{items[3]}
+	s.ItemsNotInGroups = items[:3]
+	assert.Equal(t, 0.0, scorer.ScoreGroup(s.Groups[0]))
+	assert.Equal(t, 2.0, scorer.MaxPotentialScore(s))
+	assert.Equal(t, 2.0, scorer.(DeltaScorer).ScoreGroupDelta(s.Groups[0], items[2:3], items[3:]))
+
+	_, err = newGroupEligibilityScorer(&Rule{TagName: "language", Type: RuleTypeGroupEligibility, Weight: -1}, items)
+	assert.NotEqual(t, nil, err)
+}
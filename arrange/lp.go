@@ -0,0 +1,403 @@
+package arrange
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteLP writes the problem as a mixed integer program in CPLEX LP format, which solvers like CBC, HiGHS and Gurobi
+// can solve exactly. The solution they write can be read back with ReadLPSolution.
+//
+// There is a binary variable x_i_g for each item i and group g, which is 1 if the item is in the group, and a y_g for
+// each group, which is 1 if the group has any items. Rule scores that depend on two items being in the same group use
+// a variable z_k for each pair and group. Quotas and bundles (see Options.BundleTag) are hard constraints, so a problem
+// that can't meet them all has no solution. Rule priorities are kept by multiplying each tier's score by more than the
+// lower tiers could add up to.
+//
+// Only Sameness, Relationship, GroupMatch, GroupEligibility and GroupPreference rules and Options.RepeatWeight can be
+// written this way; an error lists anything else. A quota with a Min that no item has the value for is an error too,
+// since no solution could meet it. Only the LP format is written, not MPS.
+func WriteLP(w io.Writer, items []*Item, rules []*Rule, groups []*Group, opts Options) error {
+	r := newRunner(context.Background(), items, rules, groups, opts)
+	if err := r.validateInput(); err != nil {
+		return err
+	}
+	if err := r.initBundles(); err != nil {
+		return err
+	}
+	r.initTiers()
+	if err := r.initScorers(); err != nil {
+		return err
+	}
+
+	m := &lpModel{pairVars: map[lpPair]string{}, objective: map[string]float64{}}
+	itemIndex := map[*Item]int{}
+	for i, item := range items {
+		itemIndex[item] = i
+	}
+	for _, unit := range r.unitsIn(items) {
+		if len(unit) > 1 {
+			var bundle []int
+			for _, item := range unit {
+				bundle = append(bundle, itemIndex[item])
+			}
+			m.bundles = append(m.bundles, bundle)
+		}
+	}
+
+	// Each tier's terms, to be scaled by the tier's multiplier once they're all known
+	tierTerms := make([]map[string]float64, len(r.tierByPriority))
+	for i := range tierTerms {
+		tierTerms[i] = map[string]float64{}
+	}
+	addPair := func(terms map[string]float64, i, j int, weight float64) {
+		if i > j {
+			i, j = j, i
+		}
+		for g := range groups {
+			terms[m.pairVar(i, j, g)] += weight
+		}
+	}
+
+	var unsupported []string
+	for _, ts := range r.scorers {
+		terms := tierTerms[ts.tier]
+		switch scorer := ts.scorer.(type) {
+		case *samenessScorer:
+			// A tag value's count squared is the number of items with it, plus twice the number of pairs of them
+			weight := float64(ts.rule.Weight)
+			byValue := map[string][]int{}
+			var values []string
+			for i, item := range items {
+				val := item.Tags[ts.rule.TagName]
+				if val == "" {
+					continue
+				}
+				if byValue[val] == nil {
+					values = append(values, val)
+				}
+				byValue[val] = append(byValue[val], i)
+			}
+			for _, val := range values {
+				same := byValue[val]
+				for a, i := range same {
+					for g := range groups {
+						terms[lpItemVar(i, g)] += weight
+					}
+					for _, j := range same[a+1:] {
+						addPair(terms, i, j, 2*weight)
+					}
+				}
+			}
+
+		case *relationshipScorer:
+			for _, item := range items {
+				for _, other := range scorer.related[item] {
+					addPair(terms, itemIndex[item], itemIndex[other], float64(ts.rule.Weight))
+				}
+			}
+
+		case *repeatScorer:
+			for i := range items {
+				for j := i + 1; j < len(items); j++ {
+					if count := scorer.history.Count(items[i].ID, items[j].ID); count > 0 {
+						addPair(terms, i, j, -float64(scorer.weight*count))
+					}
+				}
+			}
+
+		case *groupMatchScorer:
+			for i, item := range items {
+				for g, group := range groups {
+					if groupValues := scorer.groupValues(group); groupValues != nil && scorer.matches(groupValues, item) {
+						terms[lpItemVar(i, g)] += float64(ts.rule.Weight)
+					}
+				}
+			}
+
+		case *groupEligibilityScorer:
+			for i, item := range items {
+				for g, group := range groups {
+					if groupValues := scorer.groupValues(group); groupValues != nil && !scorer.matches(groupValues, item) {
+						terms[lpItemVar(i, g)] -= float64(ts.rule.Weight)
+					}
+				}
+			}
+
+		case *groupPreferenceScorer:
+			for i, item := range items {
+				for g, group := range groups {
+					terms[lpItemVar(i, g)] += scorer.itemScore(item, group.Name)
+				}
+			}
+
+		case *quotaScorer:
+			// These are constraints, added below
+
+		default:
+			unsupported = append(unsupported, ts.name)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("can't be written as a linear model: %s", strings.Join(unsupported, ", "))
+	}
+
+	// A constraint needs at least one term, so a quota that no item could meet can't be written as one
+	for _, group := range groups {
+		for _, quota := range group.Quotas {
+			if quota.Min > 0 && quota.count(items) == 0 {
+				return fmt.Errorf("can't be written as a linear model: group %q has a quota of at least %d with %s %q, but no item has that value",
+					group.Name, quota.Min, quota.TagName, quota.Value)
+			}
+		}
+	}
+
+	// Scale each tier so that any change in it outweighs every possible change in the lower tiers. Weights are whole
+	// numbers, so a tier's score changes by at least 1.
+	multiplier := 1.0
+	for tier := len(tierTerms) - 1; tier >= 0; tier-- {
+		var spread float64
+		for name, coef := range tierTerms[tier] {
+			m.objective[name] += coef * multiplier
+			spread += math.Abs(coef)
+		}
+		multiplier *= spread + 1
+	}
+
+	bw := bufio.NewWriter(w)
+	m.write(bw, items, groups)
+	return bw.Flush()
+}
+
+// lpPair is a pair of items, by index with i < j, in a group.
+type lpPair struct{ i, j, g int }
+
+type lpModel struct {
+	// The name of the variable for each pair of items in a group, and the pairs in the order they were added
+	pairVars map[lpPair]string
+	pairs    []lpPair
+
+	// The coefficient of each variable in the objective
+	objective map[string]float64
+
+	// The indexes of the items in each bundle (see Options.BundleTag)
+	bundles [][]int
+}
+
+func lpItemVar(i, g int) string {
+	return fmt.Sprintf("x_%d_%d", i, g)
+}
+
+func lpGroupVar(g int) string {
+	return fmt.Sprintf("y_%d", g)
+}
+
+// pairVar returns the variable that's 1 if items i and j are both in group g.
+func (m *lpModel) pairVar(i, j, g int) string {
+	p := lpPair{i, j, g}
+	if name, ok := m.pairVars[p]; ok {
+		return name
+	}
+	name := fmt.Sprintf("z_%d", len(m.pairs))
+	m.pairVars[p] = name
+	m.pairs = append(m.pairs, p)
+	return name
+}
+
+func (m *lpModel) write(w io.Writer, items []*Item, groups []*Group) {
+	fmt.Fprintln(w, `\ Written by arrangeit. x_i_g is 1 if item i is in group g:`)
+	for i, item := range items {
+		fmt.Fprintf(w, "\\   item %d: %s\n", i, item.ID)
+	}
+	for g, group := range groups {
+		fmt.Fprintf(w, "\\   group %d: %s\n", g, group.Name)
+	}
+
+	// Variables in a stable order: items, then groups, then pairs
+	var vars []string
+	for i := range items {
+		for g := range groups {
+			vars = append(vars, lpItemVar(i, g))
+		}
+	}
+	for g := range groups {
+		vars = append(vars, lpGroupVar(g))
+	}
+	for _, p := range m.pairs {
+		vars = append(vars, m.pairVars[p])
+	}
+
+	fmt.Fprintln(w, "Maximize")
+	var objective []lpTerm
+	for _, name := range vars {
+		if coef := m.objective[name]; coef != 0 {
+			objective = append(objective, lpTerm{coef, name})
+		}
+	}
+	if len(objective) == 0 {
+		// An objective needs at least one term
+		objective = append(objective, lpTerm{0, lpItemVar(0, 0)})
+	}
+	writeLPConstraint(w, "score", objective, "", 0)
+
+	fmt.Fprintln(w, "Subject To")
+	for i := range items {
+		var terms []lpTerm
+		for g := range groups {
+			terms = append(terms, lpTerm{1, lpItemVar(i, g)})
+		}
+		writeLPConstraint(w, fmt.Sprintf("item_%d", i), terms, "=", 1)
+	}
+	for _, bundle := range m.bundles {
+		// Every item in a bundle is in the same group as the first
+		for _, i := range bundle[1:] {
+			for g := range groups {
+				writeLPConstraint(w, fmt.Sprintf("bundle_%d_%d", i, g), []lpTerm{{1, lpItemVar(bundle[0], g)}, {-1, lpItemVar(i, g)}}, "=", 0)
+			}
+		}
+	}
+	for g, group := range groups {
+		var terms []lpTerm
+		for i := range items {
+			terms = append(terms, lpTerm{1, lpItemVar(i, g)})
+		}
+		// A group is either empty or has between MinSize and MaxSize items
+		writeLPConstraint(w, fmt.Sprintf("max_%d", g), append(terms, lpTerm{-float64(group.MaxSize), lpGroupVar(g)}), "<=", 0)
+		writeLPConstraint(w, fmt.Sprintf("min_%d", g), append(terms, lpTerm{-float64(group.MinSize), lpGroupVar(g)}), ">=", 0)
+
+		for q, quota := range group.Quotas {
+			var quotaTerms []lpTerm
+			for i, item := range items {
+				if item.Tags[quota.TagName] == quota.Value {
+					quotaTerms = append(quotaTerms, lpTerm{1, lpItemVar(i, g)})
+				}
+			}
+			if len(quotaTerms) == 0 {
+				// No item has the value, so there's nothing to limit; WriteLP rejects a Min that can't be met
+				continue
+			}
+			if quota.Min > 0 {
+				writeLPConstraint(w, fmt.Sprintf("quota_min_%d_%d", g, q), quotaTerms, ">=", float64(quota.Min))
+			}
+			if quota.Max != nil {
+				writeLPConstraint(w, fmt.Sprintf("quota_max_%d_%d", g, q), quotaTerms, "<=", float64(*quota.Max))
+			}
+		}
+	}
+	for _, p := range m.pairs {
+		name := m.pairVars[p]
+		xi, xj := lpItemVar(p.i, p.g), lpItemVar(p.j, p.g)
+		if m.objective[name] > 0 {
+			// Maximizing pushes z up, so it only has to be kept from being 1 unless both items are in the group
+			writeLPConstraint(w, name+"_i", []lpTerm{{1, name}, {-1, xi}}, "<=", 0)
+			writeLPConstraint(w, name+"_j", []lpTerm{{1, name}, {-1, xj}}, "<=", 0)
+		} else if m.objective[name] < 0 {
+			// Maximizing pushes z down, so it only has to be kept from being 0 when both items are in the group
+			writeLPConstraint(w, name+"_ij", []lpTerm{{1, name}, {-1, xi}, {-1, xj}}, ">=", -1)
+		}
+	}
+
+	fmt.Fprintln(w, "Binary")
+	for n, name := range vars {
+		if n%10 == 0 {
+			if n > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, " ")
+		}
+		fmt.Fprint(w, " ", name)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "End")
+}
+
+type lpTerm struct {
+	coef float64
+	name string
+}
+
+// writeLPConstraint writes a named row, wrapping long ones. If op is "", it's the objective and has no right hand side.
+func writeLPConstraint(w io.Writer, name string, terms []lpTerm, op string, rhs float64) {
+	fmt.Fprintf(w, " %s:", name)
+	for n, term := range terms {
+		if n > 0 && n%8 == 0 {
+			fmt.Fprint(w, "\n   ")
+		}
+		sign := "+"
+		if term.coef < 0 || (term.coef == 0 && math.Signbit(term.coef)) {
+			sign = "-"
+		}
+		fmt.Fprintf(w, " %s %s %s", sign, strconv.FormatFloat(math.Abs(term.coef), 'g', -1, 64), term.name)
+	}
+	if op != "" {
+		fmt.Fprintf(w, " %s %s", op, strconv.FormatFloat(rhs, 'g', -1, 64))
+	}
+	fmt.Fprintln(w)
+}
+
+// lpItemVarPattern matches the names of the x_i_g variables.
+var lpItemVarPattern = regexp.MustCompile(`^x_(\d+)_(\d+)$`)
+
+// ReadLPSolution reads a solution to a model written by WriteLP for the same items and groups, and returns copies of
+// the groups with the items the solution put in them. It accepts the solution files of CBC, HiGHS and Gurobi, and
+// others that list each variable's name followed by its value on one line.
+func ReadLPSolution(r io.Reader, items []*Item, groups []*Group) ([]*Group, error) {
+	arrangement := make([]*Group, 0, len(groups))
+	for _, group := range groups {
+		arrangement = append(arrangement, group.emptyCopy(0))
+	}
+
+	placed := make([]int, len(items))
+	for i := range placed {
+		placed[i] = -1
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for n, field := range fields {
+			match := lpItemVarPattern.FindStringSubmatch(field)
+			if match == nil || n+1 >= len(fields) {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[n+1], 64)
+			if err != nil || value < 0.5 {
+				break
+			}
+			i, _ := strconv.Atoi(match[1])
+			g, _ := strconv.Atoi(match[2])
+			if i >= len(items) || g >= len(groups) {
+				return nil, fmt.Errorf("solution has %s, but there are only %d items and %d groups", field, len(items), len(groups))
+			}
+			if placed[i] >= 0 && placed[i] != g {
+				return nil, fmt.Errorf("solution puts item %q in both %q and %q", items[i].ID, groups[placed[i]].Name, groups[g].Name)
+			}
+			if placed[i] < 0 {
+				placed[i] = g
+				arrangement[g].Items = append(arrangement[g].Items, items[i])
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for i, g := range placed {
+		if g < 0 {
+			missing = append(missing, strconv.Quote(items[i].ID))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("solution doesn't put these items in a group: %s", strings.Join(missing, ", "))
+	}
+	return arrangement, nil
+}
@@ -0,0 +1,155 @@
+package arrange
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+// lpObjective evaluates the objective of a model written by WriteLP, given the groups each item is in.
+func lpObjective(t *testing.T, model string, items []*Item, arrangement []*Group) float64 {
+	values := map[string]float64{}
+	for g, group := range arrangement {
+		for _, item := range group.Items {
+			for i := range items {
+				if items[i] == item {
+					values[lpItemVar(i, g)] = 1
+				}
+			}
+		}
+	}
+
+	section := model[strings.Index(model, "Maximize")+len("Maximize") : strings.Index(model, "Subject To")]
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(section), "score:"))
+	var objective float64
+	for n := 0; n+2 < len(fields); n += 3 {
+		coef, err := strconv.ParseFloat(fields[n+1], 64)
+		assert.Equal(t, nil, err)
+		if fields[n] == "-" {
+			coef = -coef
+		}
+		name := fields[n+2]
+		value := values[name]
+		if strings.HasPrefix(name, "z_") {
+			// Work out from the constraints which pair of items it is
+			i := strings.Index(model, " "+name+"_")
+			constraint := strings.Fields(model[i : i+strings.Index(model[i:], "\n")])
+			value = 1
+			for _, f := range constraint {
+				if strings.HasPrefix(f, "x_") && values[f] == 0 {
+					value = 0
+				}
+			}
+			if strings.HasSuffix(constraint[0], "_i:") {
+				j := strings.Index(model, " "+name+"_j:")
+				other := strings.Fields(model[j : j+strings.Index(model[j:], "\n")])
+				for _, f := range other {
+					if strings.HasPrefix(f, "x_") && values[f] == 0 {
+						value = 0
+					}
+				}
+			}
+		}
+		objective += coef * value
+	}
+	return objective
+}
+
+func TestWriteLP(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "friend": "girl1"}},
+		&Item{ID: "girl1", Tags: map[string]string{"gender": "f"}},
+		&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "girl2", Tags: map[string]string{"gender": "f"}},
+		&Item{ID: "guy3", Tags: map[string]string{"gender": "m"}},
+	}
+	rules := []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2},
+		&Rule{TagName: "friend", Type: RuleTypeRelationship, Weight: 3},
+	}
+	one := 1
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 3, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Max: &one}}},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 3},
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, nil, WriteLP(&buf, items, rules, groups, Options{}))
+	model := buf.String()
+	assert.T(t, strings.Contains(model, " max_0: + 1 x_0_0 + 1 x_1_0 + 1 x_2_0 + 1 x_3_0 + 1 x_4_0 - 3 y_0 <= 0\n"))
+	assert.T(t, strings.Contains(model, " quota_max_0_0: + 1 x_1_0 + 1 x_3_0 <= 1\n"))
+
+	// With only one rule priority, the objective is the score of the rules, which come after the quotas
+	result, err := Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{0, 26}, result.Score)
+	assert.Equal(t, result.Score[1], lpObjective(t, model, items, result.Groups))
+
+	// A solution in the form CBC writes it
+	solution := `Optimal - objective value 26.00000000
+      0 x_0_0                  1                       0
+      2 x_1_0                  1                       0
+      5 x_2_1                  1                       0
+      7 x_3_1                  1                       0
+      9 x_4_1                  1                       0
+     10 y_0                    1                       0
+`
+	arrangement, err := ReadLPSolution(strings.NewReader(solution), items, groups)
+	assert.Equal(t, nil, err)
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "guy1"}, &Item{ID: "girl1"}}},
+			&Group{Items: []*Item{&Item{ID: "guy2"}, &Item{ID: "girl2"}, &Item{ID: "guy3"}}},
+		},
+		arrangement)
+
+	_, err = ReadLPSolution(strings.NewReader("x_0_0 1\nx_1_1 1\n"), items, groups)
+	assert.Equal(t, `solution doesn't put these items in a group: "girl2", "guy2", "guy3"`, err.Error())
+
+	err = WriteLP(&buf, items, append(rules, &Rule{TagName: "home", Type: RuleTypeNearness, Weight: 1}), groups, Options{})
+	assert.Equal(t, "can't be written as a linear model: Nearness on home", err.Error())
+}
+
+func TestWriteLPQuotasWithoutMatchingItems(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "guy1", Tags: map[string]string{"gender": "m"}},
+		&Item{ID: "guy2", Tags: map[string]string{"gender": "m"}},
+	}
+	one := 1
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 1, MaxSize: 2, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Max: &one}}},
+		&Group{Name: "Group 2", MinSize: 1, MaxSize: 2},
+	}
+
+	// A Max that no item could break is left out rather than written with nothing on the left
+	var buf bytes.Buffer
+	assert.Equal(t, nil, WriteLP(&buf, items, nil, groups, Options{}))
+	assert.T(t, !strings.Contains(buf.String(), "quota_"))
+
+	// A Min that no item could meet has no solution
+	groups[0].Quotas[0].Min = 1
+	err := WriteLP(&buf, items, nil, groups, Options{})
+	assert.Equal(t, `can't be written as a linear model: group "Group 1" has a quota of at least 1 with gender "f", but no item has that value`,
+		err.Error())
+}
+
+func TestWriteLPPriorities(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "a", Tags: map[string]string{"gender": "m", "church": "c1"}},
+		&Item{ID: "b", Tags: map[string]string{"gender": "f", "church": "c1"}},
+	}
+	groups := []*Group{&Group{Name: "Group 1", MinSize: 1, MaxSize: 2}, &Group{Name: "Group 2", MinSize: 1, MaxSize: 2}}
+
+	var buf bytes.Buffer
+	assert.Equal(t, nil, WriteLP(&buf, items, []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: 1},
+		&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1},
+	}, groups, Options{}))
+
+	// The church terms can add up to at most 1+1+1+1+2+2 = 8, so the gender terms are multiplied by 9
+	assert.T(t, strings.Contains(buf.String(), " score: + 10 x_0_0 + 10 x_0_1 + 10 x_1_0 + 10 x_1_1 + 2 z_0 + 2 z_1\n"))
+}
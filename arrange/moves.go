@@ -0,0 +1,293 @@
+package arrange
+
+// move is a change to a state, made up of items being added to and removed from some of its groups.
+type move struct {
+	changes []groupChange
+
+	// The score of the state after the move
+	score Score
+}
+
+// groupChange is the change a move makes to one group.
+type groupChange struct {
+	gIndex         int
+	added, removed []*Item
+}
+
+// transfer returns the changes for moving the toG2 items from group gIndex1 to group gIndex2, and the toG1 items the
+// other way.
+func transfer(gIndex1, gIndex2 int, toG2, toG1 []*Item) []groupChange {
+	return []groupChange{
+		{gIndex: gIndex1, added: toG1, removed: toG2},
+		{gIndex: gIndex2, added: toG2, removed: toG1},
+	}
+}
+
+// moveFilter is used to only allow some moves, given the move and its resulting score.
+type moveFilter func(m *move) bool
+
+// bestMove returns the best scoring move from sourceState that allowed returns true for, or nil if there are none. If
+// allowed is nil, every move is allowed. Items in a bundle (see initBundles) are always moved together as one unit. The
+// moves tried are:
+//
+//   - moving a unit into a group that has room for it
+//   - swapping two units between groups, where they couldn't both just be moved
+//   - swapping a bundle with two units in another group
+//   - moving two units with items that are related by a Relationship rule, and in the same group, into a group with
+//     room for both
+//   - swapping two such units with two units in another group
+func (r *runner) bestMove(sourceState *State, allowed moveFilter) *move {
+	// NOTE: we'd quit faster by checking `stopReason()` in the loop here, but would also slow us down
+
+	// Each move is scored without building a new state for it (see scoreMove); only the chosen one gets built.
+	c := &moveChooser{r: r, s: sourceState, allowed: allowed}
+	groups := sourceState.Groups
+
+	// Moving a unit gives the same score as moving any other unit of its class from the same group, so only try one of
+	// each class
+	units := make([][][]*Item, len(groups))
+	for i, group := range groups {
+		units[i] = r.oneOfEachClass(r.unitsIn(group.Items))
+	}
+
+	for gIndex1, g1 := range groups {
+		for _, unit := range units[gIndex1] {
+			class := itemClass(r.itemClasses, unit[0])
+			for gIndex2, g2 := range groups {
+				if g1 == g2 {
+					continue
+				}
+
+				canMove := len(g2.Items)+len(unit) <= g2.MaxSize
+				if canMove {
+					// The group has room, try moving our current unit into it
+					c.consider(transfer(gIndex1, gIndex2, unit, nil))
+				}
+
+				// Swaps are the same whichever group they're looked at from, so only try them from the first group
+				if gIndex2 < gIndex1 {
+					continue
+				}
+				for _, unit2 := range units[gIndex2] {
+					if itemClass(r.itemClasses, unit2[0]) == class {
+						// Swapping with the same class changes nothing
+						continue
+					}
+					if canMove && len(g1.Items)+len(unit2) <= g1.MaxSize {
+						// If both could just be moved, a swap isn't worth trying as well
+						continue
+					}
+					c.consider(transfer(gIndex1, gIndex2, unit, unit2))
+				}
+			}
+		}
+	}
+
+	// A bundle can't be swapped with a smaller unit between full groups, so also try swapping bundles with two units
+	for gIndex1 := range groups {
+		for _, unit := range units[gIndex1] {
+			if len(unit) < 2 {
+				continue
+			}
+			for gIndex2, g2 := range groups {
+				if gIndex2 == gIndex1 {
+					continue
+				}
+				others := r.unitsIn(g2.Items)
+				pairsTried := map[[2]string]bool{}
+				for i := range others {
+					for j := i + 1; j < len(others); j++ {
+						classes := [2]string{itemClass(r.itemClasses, others[i][0]), itemClass(r.itemClasses, others[j][0])}
+						if classes[0] > classes[1] {
+							classes[0], classes[1] = classes[1], classes[0]
+						}
+						if pairsTried[classes] {
+							continue
+						}
+						pairsTried[classes] = true
+						c.consider(transfer(gIndex1, gIndex2, unit, joinUnits(others[i], others[j])))
+					}
+				}
+			}
+		}
+	}
+
+	for _, pair := range r.linkedPairs {
+		gIndex1 := groupIndexOf(groups, pair[0])
+		if gIndex1 < 0 || !containsItem(groups[gIndex1].Items, pair[1]) {
+			continue
+		}
+		toG2 := r.unitsIn([]*Item{pair[0], pair[1]})
+		if len(toG2) < 2 {
+			// They're in the same bundle, which is moved anyway
+			continue
+		}
+		for gIndex2, g2 := range groups {
+			if gIndex2 == gIndex1 {
+				continue
+			}
+			c.consider(transfer(gIndex1, gIndex2, joinUnits(toG2...), nil))
+			others := r.unitsIn(g2.Items)
+			for i := range others {
+				for j := i + 1; j < len(others); j++ {
+					c.consider(transfer(gIndex1, gIndex2, joinUnits(toG2...), joinUnits(others[i], others[j])))
+				}
+			}
+		}
+	}
+	return c.best
+}
+
+// bestRotation returns the best scoring rotation of three units (see initBundles) between three groups from
+// sourceState that allowed returns true for, or nil if there are none: a unit from one group moves to a second, one from
+// the second moves to a third, and one from the third moves to the first. These can get past arrangements where no
+// single move or swap helps, but there are many more of them to try.
+func (r *runner) bestRotation(sourceState *State, allowed moveFilter) *move {
+	c := &moveChooser{r: r, s: sourceState, allowed: allowed}
+	groups := sourceState.Groups
+	units := make([][][]*Item, len(groups))
+	for i, group := range groups {
+		units[i] = r.oneOfEachClass(r.unitsIn(group.Items))
+	}
+
+	// A rotation starting from any of its groups is the same, so start from the one that comes first
+	for gIndex1 := range groups {
+		for gIndex2 := gIndex1 + 1; gIndex2 < len(groups); gIndex2++ {
+			for gIndex3 := gIndex1 + 1; gIndex3 < len(groups); gIndex3++ {
+				if gIndex3 == gIndex2 {
+					continue
+				}
+				for _, unit1 := range units[gIndex1] {
+					for _, unit2 := range units[gIndex2] {
+						for _, unit3 := range units[gIndex3] {
+							c.consider([]groupChange{
+								{gIndex: gIndex1, added: unit3, removed: unit1},
+								{gIndex: gIndex2, added: unit1, removed: unit2},
+								{gIndex: gIndex3, added: unit2, removed: unit3},
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return c.best
+}
+
+// oneOfEachClass returns the first of the units with each item class (see initItemClasses). Items in bundles are only
+// interchangeable with themselves, so a unit's class is that of its first item.
+func (r *runner) oneOfEachClass(units [][]*Item) [][]*Item {
+	var unique [][]*Item
+	seen := map[string]bool{}
+	for _, unit := range units {
+		if class := itemClass(r.itemClasses, unit[0]); !seen[class] {
+			seen[class] = true
+			unique = append(unique, unit)
+		}
+	}
+	return unique
+}
+
+// joinUnits returns the items of the units in one new slice.
+func joinUnits(units ...[]*Item) []*Item {
+	var items []*Item
+	for _, unit := range units {
+		items = append(items, unit...)
+	}
+	return items
+}
+
+// moveChooser keeps track of the best move seen.
+type moveChooser struct {
+	r       *runner
+	s       *State
+	allowed moveFilter
+	best    *move
+}
+
+func (c *moveChooser) consider(changes []groupChange) {
+	for _, change := range changes {
+		group := c.s.Groups[change.gIndex]
+		if len(group.Items)-len(change.removed)+len(change.added) > group.MaxSize {
+			return
+		}
+	}
+
+	score := c.r.scoreMove(c.s, changes)
+	if c.best != nil && !score.Better(c.best.score) {
+		return
+	}
+	m := &move{changes: changes, score: score}
+	if c.allowed == nil || c.allowed(m) {
+		c.best = m
+	}
+}
+
+// applyMove returns a copy of s with the move made.
+func (r *runner) applyMove(s *State, m *move) *State {
+	next := s.Copy()
+	for _, c := range m.changes {
+		group := next.Groups[c.gIndex]
+		group.Items = append(removeItems(group.Items, c.removed), c.added...)
+	}
+	next.Score = r.CalculateScore(next)
+	return next
+}
+
+// scoreMove returns what the score of s would be after the changes, without modifying s.
+// When it can, it only works out how the score of the changed groups changes rather than scoring the whole state again.
+func (r *runner) scoreMove(s *State, changes []groupChange) Score {
+	if !s.IsTerminal() || !s.meetsMinSizes() {
+		// The score of s isn't a sum of group scores we can adjust, so build the new state and score it from scratch
+		return r.applyMove(s, &move{changes: changes}).Score
+	}
+
+	for _, c := range changes {
+		group := s.Groups[c.gIndex]
+		newSize := len(group.Items) - len(c.removed) + len(c.added)
+		if newSize > 0 && newSize < group.MinSize {
+			return r.worstScore()
+		}
+	}
+
+	score := append(Score(nil), s.Score...)
+	for _, ts := range r.scorers {
+		for _, c := range changes {
+			score[ts.tier] += groupScoreDelta(ts.scorer, s.Groups[c.gIndex], c.added, c.removed)
+		}
+	}
+	return score
+}
+
+// initLinkedPairs finds the pairs of items that a Relationship rule with a positive weight wants together, which
+// bestMove tries moving together. initScorers must have been called first.
+func (r *runner) initLinkedPairs() {
+	r.linkedPairs = nil
+	seen := map[[2]*Item]bool{}
+	for _, ts := range r.scorers {
+		rs, ok := ts.scorer.(*relationshipScorer)
+		if !ok || rs.rule.Weight <= 0 {
+			continue
+		}
+		for _, item := range r.items {
+			for _, other := range rs.related[item] {
+				pair := [2]*Item{item, other}
+				if seen[pair] || seen[[2]*Item{other, item}] {
+					continue
+				}
+				seen[pair] = true
+				r.linkedPairs = append(r.linkedPairs, pair)
+			}
+		}
+	}
+}
+
+// groupIndexOf returns the index of the group the item is in, or -1 if it isn't in any.
+func groupIndexOf(groups []*Group, item *Item) int {
+	for i, group := range groups {
+		if containsItem(group.Items, item) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,73 @@
+package arrange
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestBestMoveMovesLinkedPairs(t *testing.T) {
+	x := &Item{ID: "x", Tags: map[string]string{"friend": "y,q"}}
+	y := &Item{ID: "y", Tags: map[string]string{"friend": "x,q"}}
+	z := &Item{ID: "z", Tags: map[string]string{"friend": ""}}
+	q := &Item{ID: "q", Tags: map[string]string{"friend": "w"}}
+	w := &Item{ID: "w", Tags: map[string]string{"friend": "q"}}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 1, MaxSize: 4},
+	}
+	r := newTestRunner(t, []*Item{x, y, z, q, w}, []*Rule{
+		&Rule{TagName: "friend", Type: RuleTypeRelationship, Weight: 1},
+	}, groups, Options{})
+	assert.Equal(t, [][2]*Item{{x, y}, {x, q}, {y, q}, {q, w}}, r.linkedPairs)
+
+	s := &State{Groups: []*Group{
+		&Group{MinSize: 1, MaxSize: 3, Items: []*Item{x, y, z}},
+		&Group{MinSize: 1, MaxSize: 4, Items: []*Item{q, w}},
+	}}
+	s.Score = r.CalculateScore(s)
+
+	// Moving or swapping x or y alone splits them up, so only moving them together helps
+	m := r.bestMove(s, nil)
+	assert.Equal(t, Score{6}, m.score)
+	next := r.applyMove(s, m)
+	assert.Equal(t, []*Item{z}, next.Groups[0].Items)
+	assert.Equal(t, []*Item{q, w, x, y}, next.Groups[1].Items)
+
+	r.linkedPairs = nil
+	m = r.bestMove(s, nil)
+	assert.T(t, !m.score.Better(s.Score))
+}
+
+func TestBestRotation(t *testing.T) {
+	var items []*Item
+	newItem := func(id, color string) *Item {
+		item := &Item{ID: id, Tags: map[string]string{"color": color}}
+		items = append(items, item)
+		return item
+	}
+	r1, r2, r3 := newItem("r1", "red"), newItem("r2", "red"), newItem("r3", "red")
+	g1, g2, g3 := newItem("g1", "green"), newItem("g2", "green"), newItem("g3", "green")
+	b1, b2, b3 := newItem("b1", "blue"), newItem("b2", "blue"), newItem("b3", "blue")
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 3, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 3, MaxSize: 3},
+		&Group{Name: "Group 3", MinSize: 3, MaxSize: 3},
+	}
+	r := newTestRunner(t, items, []*Rule{
+		&Rule{TagName: "color", Type: RuleTypeSameness, Weight: 1},
+	}, groups, Options{})
+
+	s := &State{Groups: []*Group{
+		&Group{MinSize: 3, MaxSize: 3, Items: []*Item{r1, r2, g1}},
+		&Group{MinSize: 3, MaxSize: 3, Items: []*Item{g2, g3, b1}},
+		&Group{MinSize: 3, MaxSize: 3, Items: []*Item{b2, b3, r3}},
+	}}
+	s.Score = r.CalculateScore(s)
+
+	// A swap can only finish one color, but a rotation finishes all three
+	assert.Equal(t, Score{19}, r.bestMove(s, nil).score)
+	m := r.bestRotation(s, nil)
+	assert.Equal(t, Score{27}, m.score)
+	assert.Equal(t, Score{27}, r.applyMove(s, m).Score)
+}
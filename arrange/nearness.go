@@ -0,0 +1,206 @@
+package arrange
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nearnessScorer implements RuleTypeNearness.
+type nearnessScorer struct {
+	rule *Rule
+
+	// The tag value of each item parsed as a point, so we don't have to re-parse these over and over. Items without a
+	// valid point for the tag are left out.
+	points map[*Item]point
+
+	// The distribution of the points of every item, see getDistribution
+	maxDistribution float64
+}
+
+func newNearnessScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	ns := &nearnessScorer{rule: rule, points: map[*Item]point{}}
+
+	var points []point
+	for _, item := range items {
+		val := item.Tags[rule.TagName]
+		if val == "" {
+			continue
+		}
+		p, err := parsePoint(val)
+		if err != nil {
+			log.Printf("Failed to parse point: %v", err)
+			continue
+		}
+		ns.points[item] = p
+		points = append(points, p)
+	}
+	ns.maxDistribution = getDistribution(points)
+	return ns, nil
+}
+
+func (ns *nearnessScorer) ScoreGroup(group *Group) float64 {
+	// We score "nearness" by getting a distribution ratio for the points in the group, relative to the distribution of
+	// all points in all items. I.e. if the current group's items are within a very small distance of each other, much
+	// smaller than the general distribution of points, then the distributionRatio will be close to 0. If they are far
+	// apart it'll be near 1. (Smaller is better)
+	distribution, numPoints := ns.groupDistribution(group)
+	distributionRatio := ns.distributionRatio(distribution)
+
+	// This scoring rewards many points being together that still have a low distribution ratio.
+	return float64(ns.rule.Weight) * float64(numPoints) * (1 - distributionRatio)
+}
+
+func (ns *nearnessScorer) MaxPotentialScore(s *State) float64 {
+	// If the rule weight is negative, the best we could theoretically do is keep the score at 0
+	if ns.rule.Weight < 0 {
+		return 0
+	}
+
+	var itemsWithPoints int
+	for _, item := range s.ItemsNotInGroups {
+		if _, ok := ns.points[item]; ok {
+			itemsWithPoints++
+		}
+	}
+
+	// The absolute maximum score here is `rule.Weight * itemsWithPoints`, assuming that each item still to be
+	// placed is placed with a group that gets maximum score for nearness.
+	// But we can guarantee the max score is lower than that for groups that already have a non-0 distribution.
+	// Since the distribution can only go up, any new point added to such groups won't be worth a full
+	// `rule.Weight`, it'll be worth less, depending on how distributed that group is.
+	// So we go through each group gathering the distribution and the number of slots left, sort them so the
+	// lowest distribution ones are first, then "fill" them in order.
+	type groupToFill struct {
+		distribution float64
+		slotsLeft    int
+	}
+	groupsToFill := make([]groupToFill, 0, len(s.Groups))
+	for _, group := range s.Groups {
+		distribution, _ := ns.groupDistribution(group)
+		slotsLeft := group.MaxSize - len(group.Items)
+		if slotsLeft > 0 {
+			groupsToFill = append(groupsToFill, groupToFill{distribution, slotsLeft})
+		}
+	}
+	sort.Slice(groupsToFill, func(i, j int) bool { return groupsToFill[i].distribution < groupsToFill[j].distribution })
+
+	var maxScore float64
+	for i := 0; itemsWithPoints > 0 && i < len(groupsToFill); i++ {
+		groupToFill := groupsToFill[i]
+
+		var numToFill int
+		if itemsWithPoints >= groupToFill.slotsLeft {
+			numToFill = groupToFill.slotsLeft
+		} else {
+			numToFill = itemsWithPoints
+		}
+		itemsWithPoints -= numToFill
+
+		// For an explanation of this calculation see ScoreGroup
+		distributionRatio := ns.distributionRatio(groupToFill.distribution)
+		maxScore += float64(ns.rule.Weight) * float64(numToFill) * (1 - distributionRatio)
+	}
+	return maxScore
+}
+
+// distributionRatio returns the distribution relative to that of all the points, see ScoreGroup.
+func (ns *nearnessScorer) distributionRatio(distribution float64) float64 {
+	if ns.maxDistribution == 0 {
+		// All the points are in the same place, so every group has them as near as they can be
+		return 0
+	}
+	return distribution / ns.maxDistribution
+}
+
+// Functions for calculating geolocation/distribution
+//
+
+type point struct {
+	x float64
+	y float64
+}
+
+func parsePoint(str string) (point, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return point{}, fmt.Errorf("failed to interpret %q as x/y coordinate", str)
+	}
+	var err error
+	var p point
+	p.x, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return point{}, fmt.Errorf("failed to parse x coordinate of %q: %v", str, err)
+	}
+	p.y, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return point{}, fmt.Errorf("failed to parse y coordinate of %q: %v", str, err)
+	}
+	return p, nil
+}
+
+// groupDistribution returns the distribution of the points in the provided group (see getDistribution) along with the
+// number of points there are. It copies some of getDistribution for performance reasons.
+func (ns *nearnessScorer) groupDistribution(group *Group) (float64, int) {
+	var maxX, maxY, minX, minY float64
+	var numPoints int
+
+	for _, item := range group.Items {
+		if p, ok := ns.points[item]; ok {
+			if numPoints > 0 {
+				if p.x > maxX {
+					maxX = p.x
+				}
+				if p.x < minX {
+					minX = p.x
+				}
+				if p.y > maxY {
+					maxY = p.y
+				}
+				if p.y < minY {
+					minY = p.y
+				}
+			} else {
+				maxX = p.x
+				minX = p.x
+				maxY = p.y
+				minY = p.y
+			}
+			numPoints++
+		}
+	}
+
+	if numPoints > 0 {
+		return (maxX - minX) + (maxY - minY), numPoints
+	} else {
+		return 0, numPoints
+	}
+}
+
+// getDistribution calculates how distributed the provided points are.
+// For the moment it just figures out the smallest square (min X,Y and max X,Y) that captures all the points and returns
+// the width + height of the box.
+func getDistribution(points []point) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	maxX, maxY := points[0].x, points[0].y
+	minX, minY := maxX, maxY
+	for _, p := range points[1:] {
+		if p.x > maxX {
+			maxX = p.x
+		}
+		if p.x < minX {
+			minX = p.x
+		}
+		if p.y > maxY {
+			maxY = p.y
+		}
+		if p.y < minY {
+			minY = p.y
+		}
+	}
+	return (maxX - minX) + (maxY - minY)
+}
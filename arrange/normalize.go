@@ -0,0 +1,269 @@
+package arrange
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Normalization cleans up the values of one tag, so that e.g. "M", "male" and "Male " are all treated as "m". It is
+// configured with these rule Params, on any rule for the tag:
+//
+//	trim             remove whitespace from the start and end of values
+//	fold             make values lower case
+//	map=a:b|c:d      replace the value a with b and c with d, after trimming and folding
+//	mapFile=path     replace values as listed in a CSV file with the columns From and To. A relative path is
+//	                 relative to the working directory, not to the file the rules are in.
+//	buckets=13-15|16-18|19-
+//	                 replace numbers with the range they're in, e.g. 14 with "13-15". Ranges may be open-ended, like
+//	                 "-12" or "19-". Values that aren't numbers or aren't in a range are left alone.
+type Normalization struct {
+	TagName   string
+	TrimSpace bool
+	FoldCase  bool
+
+	// Maps a trimmed and folded value to what it should be replaced with
+	Synonyms map[string]string
+
+	Buckets []Bucket
+}
+
+// Bucket is a range of numbers, inclusive, that are replaced with the Label.
+type Bucket struct {
+	Label    string
+	Min, Max float64
+}
+
+// Normalize returns the normalized form of a value.
+func (n *Normalization) Normalize(value string) string {
+	if n.TrimSpace {
+		value = strings.TrimSpace(value)
+	}
+	if n.FoldCase {
+		value = strings.ToLower(value)
+	}
+	if synonym, ok := n.Synonyms[value]; ok {
+		value = synonym
+	}
+	if len(n.Buckets) > 0 {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			for _, bucket := range n.Buckets {
+				if f >= bucket.Min && f <= bucket.Max {
+					return bucket.Label
+				}
+			}
+		}
+	}
+	return value
+}
+
+// NormalizationsFromRules returns the normalization for each tag that has one configured in the Params of its rules
+// (see Normalization). Rules for the same tag add to each other's normalization, except that only one may set buckets.
+func NormalizationsFromRules(rules []*Rule) ([]*Normalization, error) {
+	var norms []*Normalization
+	byTagName := map[string]*Normalization{}
+	for _, rule := range rules {
+		n, ok := byTagName[rule.TagName]
+		if !ok {
+			n = &Normalization{TagName: rule.TagName, Synonyms: map[string]string{}}
+		}
+		used := false
+		for key, value := range rule.Params {
+			var err error
+			switch key {
+			case "trim":
+				n.TrimSpace = n.TrimSpace || paramEnabled(value)
+			case "fold":
+				n.FoldCase = n.FoldCase || paramEnabled(value)
+			case "map":
+				err = parseSynonyms(value, n.Synonyms)
+			case "mapFile":
+				err = readSynonymsFile(value, n.Synonyms)
+			case "buckets":
+				if len(n.Buckets) > 0 {
+					err = fmt.Errorf("buckets are set by more than one rule")
+				} else {
+					n.Buckets, err = parseBuckets(value)
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("bad configuration: %s rule for tag %q: %s: %v", rule.Type, rule.TagName, key, err)
+			}
+			used = true
+		}
+		if used && !ok {
+			byTagName[rule.TagName] = n
+			norms = append(norms, n)
+		}
+	}
+
+	// Synonyms are looked up after trimming and folding, so do the same to the values being replaced
+	for _, n := range norms {
+		froms := make([]string, 0, len(n.Synonyms))
+		for from := range n.Synonyms {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+
+		synonyms := make(map[string]string, len(n.Synonyms))
+		cleanedFrom := map[string]string{}
+		for _, from := range froms {
+			cleaned := from
+			if n.TrimSpace {
+				cleaned = strings.TrimSpace(cleaned)
+			}
+			if n.FoldCase {
+				cleaned = strings.ToLower(cleaned)
+			}
+			to := n.Synonyms[from]
+			if prev, ok := cleanedFrom[cleaned]; ok && synonyms[cleaned] != to {
+				return nil, fmt.Errorf("bad configuration: normalization of tag %q: %q and %q are the same value, but are "+
+					"replaced with %q and %q", n.TagName, prev, from, synonyms[cleaned], to)
+			}
+			cleanedFrom[cleaned] = from
+			synonyms[cleaned] = to
+		}
+		n.Synonyms = synonyms
+	}
+	return norms, nil
+}
+
+// paramEnabled returns whether a flag-like param is on. A param given with no value, like "trim", is on.
+func paramEnabled(value string) bool {
+	switch strings.ToLower(value) {
+	case "false", "no", "0", "off":
+		return false
+	}
+	return true
+}
+
+// parseSynonyms parses synonyms written as "from1:to1|from2:to2" into synonyms.
+func parseSynonyms(str string, synonyms map[string]string) error {
+	for _, pair := range strings.Split(str, "|") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%q should be written as from:to", pair)
+		}
+		synonyms[parts[0]] = parts[1]
+	}
+	return nil
+}
+
+// readSynonymsFile reads synonyms from a CSV file with the columns From and To into synonyms.
+func readSynonymsFile(path string, synonyms map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	recs, errs := readCSVRecords(f, path, CSVOptions{})
+	errs = append(errs, recs.checkColumns([]string{"From", "To"}, []string{"From", "To"})...)
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, rec := range recs.rows {
+		var from, to string
+		for i, value := range rec.values {
+			switch recs.header[i] {
+			case "From":
+				from = value
+			case "To":
+				to = value
+			}
+		}
+		synonyms[from] = to
+	}
+	return nil
+}
+
+// parseBuckets parses ranges of numbers written as "13-15|16-18|19-".
+func parseBuckets(str string) ([]Bucket, error) {
+	var buckets []Bucket
+	for _, label := range strings.Split(str, "|") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		// Look for the separating "-" after the first character, so that the minimum can be negative
+		i := strings.Index(label[1:], "-") + 1
+		if label[0] == '-' && i == 0 {
+			i = 0
+		} else if i == 0 {
+			return nil, fmt.Errorf("%q should be a range like 13-15", label)
+		}
+
+		bucket := Bucket{Label: label, Min: math.Inf(-1), Max: math.Inf(1)}
+		var err error
+		if min := strings.TrimSpace(label[:i]); min != "" {
+			if bucket.Min, err = strconv.ParseFloat(min, 64); err != nil {
+				return nil, fmt.Errorf("%q should be a range like 13-15", label)
+			}
+		}
+		if max := strings.TrimSpace(label[i+1:]); max != "" {
+			if bucket.Max, err = strconv.ParseFloat(max, 64); err != nil {
+				return nil, fmt.Errorf("%q should be a range like 13-15", label)
+			}
+		}
+		if bucket.Min > bucket.Max {
+			return nil, fmt.Errorf("%q has its minimum above its maximum", label)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// ValueMapping is how many items had a raw tag value, and what it was normalized to.
+type ValueMapping struct {
+	TagName    string
+	Raw        string
+	Normalized string
+	Count      int
+}
+
+// NormalizeItems replaces the items' tag values with their normalized forms. It returns every distinct raw value of the
+// normalized tags and what it became, sorted by tag name, normalized value and raw value.
+func NormalizeItems(items []*Item, norms []*Normalization) []ValueMapping {
+	type key struct{ tagName, raw string }
+	mappings := map[key]*ValueMapping{}
+	for _, n := range norms {
+		for _, item := range items {
+			raw, ok := item.Tags[n.TagName]
+			if !ok {
+				continue
+			}
+			normalized := n.Normalize(raw)
+			item.Tags[n.TagName] = normalized
+
+			k := key{n.TagName, raw}
+			if mappings[k] == nil {
+				mappings[k] = &ValueMapping{TagName: n.TagName, Raw: raw, Normalized: normalized}
+			}
+			mappings[k].Count++
+		}
+	}
+
+	report := make([]ValueMapping, 0, len(mappings))
+	for _, m := range mappings {
+		report = append(report, *m)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.TagName != b.TagName {
+			return a.TagName < b.TagName
+		}
+		if a.Normalized != b.Normalized {
+			return a.Normalized < b.Normalized
+		}
+		return a.Raw < b.Raw
+	})
+	return report
+}
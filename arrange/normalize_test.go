@@ -0,0 +1,83 @@
+package arrange
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestNormalizeItems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arrangeit")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	mapFile := filepath.Join(dir, "genders.csv")
+	assert.Equal(t, nil, ioutil.WriteFile(mapFile, []byte("From,To\nboy,m\ngirl,f\n"), 0644))
+
+	rules := []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: -1,
+			Params: map[string]string{"trim": "", "fold": "", "map": "Male:m|female:f"}},
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1, Priority: 1,
+			Params: map[string]string{"mapFile": mapFile}},
+		&Rule{TagName: "age", Type: RuleTypeSameness, Weight: 1, Params: map[string]string{"buckets": "-12|13-15|16-18"}},
+		&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1},
+	}
+	norms, err := NormalizationsFromRules(rules)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(norms))
+
+	items := []*Item{
+		&Item{ID: "a", Tags: map[string]string{"gender": " MALE", "age": "14", "church": " First "}},
+		&Item{ID: "b", Tags: map[string]string{"gender": "Boy", "age": "11.5"}},
+		&Item{ID: "c", Tags: map[string]string{"gender": "f", "age": "unknown"}},
+		&Item{ID: "d", Tags: map[string]string{"gender": "Female", "age": "19"}},
+		&Item{ID: "e", Tags: map[string]string{"gender": "male", "age": "15"}},
+	}
+	report := NormalizeItems(items, norms)
+
+	assert.Equal(t, map[string]string{"gender": "m", "age": "13-15", "church": " First "}, items[0].Tags)
+	assert.Equal(t, map[string]string{"gender": "m", "age": "-12"}, items[1].Tags)
+	assert.Equal(t, map[string]string{"gender": "f", "age": "unknown"}, items[2].Tags)
+	assert.Equal(t, map[string]string{"gender": "f", "age": "19"}, items[3].Tags)
+	assert.Equal(t, []ValueMapping{
+		{TagName: "age", Raw: "11.5", Normalized: "-12", Count: 1},
+		{TagName: "age", Raw: "14", Normalized: "13-15", Count: 1},
+		{TagName: "age", Raw: "15", Normalized: "13-15", Count: 1},
+		{TagName: "age", Raw: "19", Normalized: "19", Count: 1},
+		{TagName: "age", Raw: "unknown", Normalized: "unknown", Count: 1},
+		{TagName: "gender", Raw: "Female", Normalized: "f", Count: 1},
+		{TagName: "gender", Raw: "f", Normalized: "f", Count: 1},
+		{TagName: "gender", Raw: " MALE", Normalized: "m", Count: 1},
+		{TagName: "gender", Raw: "Boy", Normalized: "m", Count: 1},
+		{TagName: "gender", Raw: "male", Normalized: "m", Count: 1},
+	}, report)
+}
+
+func TestNormalizationSynonymsThatClash(t *testing.T) {
+	// The same value after folding may be replaced with the same thing twice, but not with different things
+	norms, err := NormalizationsFromRules([]*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1,
+		Params: map[string]string{"fold": "", "map": "Male:m|male:m|MALE:m"}}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, map[string]string{"male": "m"}, norms[0].Synonyms)
+
+	_, err = NormalizationsFromRules([]*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1,
+		Params: map[string]string{"fold": "", "map": "Male:m|male:x"}}})
+	assert.Equal(t, `bad configuration: normalization of tag "gender": "Male" and "male" are the same value, but are replaced with "m" and "x"`, err.Error())
+}
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := parseBuckets("-5--1|0-9.5|10-")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(buckets))
+	assert.Equal(t, -5.0, buckets[0].Min)
+	assert.Equal(t, -1.0, buckets[0].Max)
+	assert.Equal(t, 9.5, buckets[1].Max)
+	assert.Equal(t, 10.0, buckets[2].Min)
+
+	for _, bad := range []string{"teens", "15-13", "a-b"} {
+		_, err := parseBuckets(bad)
+		assert.NotEqual(t, nil, err)
+	}
+}
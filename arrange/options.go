@@ -0,0 +1,187 @@
+package arrange
+
+import (
+	"context"
+	"time"
+)
+
+// Options are the solver settings for Arrange. The zero value uses the defaults.
+type Options struct {
+	// If non-zero, return the best arrangement found so far after this long
+	Timeout time.Duration
+
+	// The search algorithm to use, SolverHillClimbing if empty
+	Solver Solver
+
+	// How to make the arrangements the search starts from, used in turn, e.g. to mix clustering by location with random
+	// starting points. SeedingRandom is always used too, after the others if it isn't listed.
+	Seedings []Seeding
+
+	// If set, an arrangement to start the search from before any made by Seedings, e.g. a previous result to improve
+	// on. Its groups and items are matched to the ones being arranged by Name and ID, and ones that don't match are
+	// ignored. Items it doesn't place, or that no longer fit in their group, are spread across the groups with room.
+	Start []*Group
+
+	// How many moves an item is kept from being moved back into the group it was moved out of, with SolverTabu. If
+	// zero, it's based on the number of items.
+	TabuTenure int
+
+	// How many moves SolverTabu makes in a row without finding a better arrangement before it stops. If zero,
+	// DefaultTabuPatience is used.
+	TabuPatience int
+
+	// If non-zero, stop after restarting from a new random state this many times in a row without finding a better
+	// arrangement. SolverTabu doesn't restart, so this doesn't apply to it; see TabuPatience.
+	StopAfterRestarts int
+
+	// If non-zero, stop when no better arrangement has been found for this long
+	StopAfterNoImprovement time.Duration
+
+	// If set, stop as soon as an arrangement scoring at least this well is found. It must have one entry per rule
+	// priority tier (see Score).
+	TargetScore Score
+
+	// If non-zero, stop after exploring this many different states
+	MaxStates int
+
+	// How many explored states to remember, so that they aren't explored again. When more have been explored, the
+	// oldest are forgotten. If zero, DefaultMaxVisitedStates is used; if negative, there's no limit, and a long search
+	// keeps using more memory.
+	MaxVisitedStates int
+
+	// If set, items with the same value of this tag, like the members of a family, are always put in the same group.
+	// Items without a value for it are placed on their own.
+	BundleTag string
+
+	// Pairings of items from previous rounds, e.g. previous days of a rotation. May be nil. It is not modified.
+	PairHistory *PairHistory
+
+	// How much to reduce the score by for each time a pair of items in the same group has been together before
+	// according to PairHistory. This trades off against the weights of rules with the same priority.
+	RepeatWeight int
+
+	// The rule priority tier (see Rule.Priority) that the RepeatWeight penalty is scored in
+	RepeatPriority int
+
+	// If set, called with each new best arrangement as it is found. It is called from the goroutine doing the search,
+	// so it should return quickly.
+	Progress func(Progress)
+}
+
+// Solver is a search algorithm that Arrange can use.
+type Solver string
+
+const (
+	// Repeatedly make the best move or swap of items between groups until none improve the score, then start again
+	// from a new arrangement. This is the default.
+	SolverHillClimbing Solver = "hill-climbing"
+
+	// Keep making the best move or swap of items between groups, even when it makes the score worse, but don't undo
+	// recent moves (see Options.TabuTenure) unless that would beat the best arrangement so far. This can get past
+	// arrangements that hill climbing gets stuck at.
+	SolverTabu Solver = "tabu"
+)
+
+// Progress is a new best arrangement found during a search, passed to Options.Progress.
+type Progress struct {
+	// The arrangement, which must not be modified
+	Best *Result
+
+	// How long the search has been running
+	Elapsed time.Duration
+
+	// How many states have been explored. A state may be counted twice if it was forgotten (see Visited) and then
+	// explored again.
+	StatesTried int
+
+	// How many explored states are remembered (see Options.MaxVisitedStates), and roughly how many bytes they take
+	Visited       int
+	VisitedMemory int64
+
+	// How many times the search has restarted from a new random state
+	Restarts int
+}
+
+// Result is the outcome of Arrange.
+type Result struct {
+	// Copies of the input Groups with Items filled in
+	Groups []*Group
+
+	// The score of the arrangement, with one entry per rule priority tier (see Score)
+	Score Score
+
+	// How much each rule contributed to Score
+	Breakdown []RuleScore
+
+	// Why the search ended
+	StopReason StopReason
+}
+
+// StopReason is why a search ended.
+type StopReason string
+
+const (
+	// Every possible arrangement was tried
+	StopReasonExhausted StopReason = "exhausted"
+
+	// The arrangement scores as well as the rules allow, so nothing better can be found. This relies on the rules'
+	// RuleScorer.MaxPotentialScore, so it only happens when their bounds are tight enough.
+	StopReasonOptimal StopReason = "optimal"
+
+	// The Timeout or the context's deadline passed
+	StopReasonTimeout StopReason = "timeout"
+
+	// The context was canceled
+	StopReasonCanceled StopReason = "canceled"
+
+	// Options.StopAfterRestarts, Options.TabuPatience or Options.StopAfterNoImprovement was reached
+	StopReasonNoImprovement StopReason = "no-improvement"
+
+	// Options.TargetScore was reached
+	StopReasonTargetScore StopReason = "target-score"
+
+	// Options.MaxStates was reached
+	StopReasonMaxStates StopReason = "max-states"
+)
+
+// Arrange is like GetArrangement but accepts solver settings and returns more detail about the result.
+func Arrange(ctx context.Context, items []*Item, rules []*Rule, groups []*Group, opts Options) (*Result, error) {
+	if opts.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	r := newRunner(ctx, items, rules, groups, opts)
+	return r.run()
+}
+
+// reportProgress passes the current best state to Options.Progress, if set.
+func (r *runner) reportProgress() {
+	if r.opts.Progress == nil {
+		return
+	}
+	r.opts.Progress(Progress{
+		Best: &Result{
+			Groups:    r.bestState.Groups,
+			Score:     r.bestState.Score,
+			Breakdown: r.breakdown(r.bestState.Groups),
+		},
+		Elapsed:       time.Since(r.start),
+		StatesTried:   r.statesTried,
+		Visited:       r.visited.len(),
+		VisitedMemory: r.visited.memoryUsage(),
+		Restarts:      r.restarts,
+	})
+}
+
+func newRunner(ctx context.Context, items []*Item, rules []*Rule, groups []*Group, opts Options) *runner {
+	return &runner{
+		ctx:     ctx,
+		items:   items,
+		rules:   rules,
+		groups:  groups,
+		opts:    opts,
+		visited: newVisitedStates(opts.MaxVisitedStates),
+	}
+}
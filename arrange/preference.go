@@ -0,0 +1,163 @@
+package arrange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// groupPreferenceScorer implements RuleTypeGroupPreference. The tag value of an item is a comma-separated list of the
+// names of the groups it would like to be in, best first, and the rule's weight is multiplied by the points for the
+// rank of the group the item is in.
+type groupPreferenceScorer struct {
+	rule *Rule
+
+	// The points for each rank, best first. Ranks past the end get no points.
+	points []float64
+
+	// The points for being in a group the item didn't rank
+	unranked float64
+
+	// The groups each item ranked, best first. Items without choices are left out.
+	choices map[*Item][]string
+
+	// The most each item could add to the score, for MaxPotentialScore
+	best map[*Item]float64
+}
+
+func newGroupPreferenceScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	ps := &groupPreferenceScorer{rule: rule, choices: map[*Item][]string{}, best: map[*Item]float64{}}
+	var maxChoices int
+	for _, item := range items {
+		if choices := splitList(item.Tags[rule.TagName]); len(choices) > 0 {
+			ps.choices[item] = choices
+			if len(choices) > maxChoices {
+				maxChoices = len(choices)
+			}
+		}
+	}
+
+	if str, ok := rule.Params["points"]; ok {
+		for _, p := range strings.Split(str, "|") {
+			points, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return nil, fmt.Errorf("points: %q is not a number", p)
+			}
+			ps.points = append(ps.points, points)
+		}
+	} else {
+		// By default, the last of the longest list of choices gets 1 point, and each better choice 1 more
+		for rank := 0; rank < maxChoices; rank++ {
+			ps.points = append(ps.points, float64(maxChoices-rank))
+		}
+	}
+	if str, ok := rule.Params["unranked"]; ok {
+		var err error
+		if ps.unranked, err = strconv.ParseFloat(strings.TrimSpace(str), 64); err != nil {
+			return nil, fmt.Errorf("unranked: %q is not a number", str)
+		}
+	}
+
+	for item, choices := range ps.choices {
+		// The item could always end up in a group it didn't rank, or in any it did
+		best := ps.itemScore(item, "")
+		for _, name := range choices {
+			if score := ps.itemScore(item, name); score > best {
+				best = score
+			}
+		}
+		ps.best[item] = best
+	}
+	return ps, nil
+}
+
+// rank returns the index of the group in the item's choices, or -1 if it didn't rank it.
+func (ps *groupPreferenceScorer) rank(item *Item, groupName string) int {
+	for rank, name := range ps.choices[item] {
+		if name == groupName {
+			return rank
+		}
+	}
+	return -1
+}
+
+// itemScore returns how much the item adds to the score in the named group.
+func (ps *groupPreferenceScorer) itemScore(item *Item, groupName string) float64 {
+	if _, ok := ps.choices[item]; !ok {
+		return 0
+	}
+	rank := ps.rank(item, groupName)
+	switch {
+	case rank < 0:
+		return float64(ps.rule.Weight) * ps.unranked
+	case rank < len(ps.points):
+		return float64(ps.rule.Weight) * ps.points[rank]
+	}
+	return 0
+}
+
+func (ps *groupPreferenceScorer) ScoreGroup(group *Group) float64 {
+	var score float64
+	for _, item := range group.Items {
+		score += ps.itemScore(item, group.Name)
+	}
+	return score
+}
+
+func (ps *groupPreferenceScorer) ScoreGroupDelta(group *Group, added, removed []*Item) float64 {
+	var delta float64
+	for _, item := range added {
+		delta += ps.itemScore(item, group.Name)
+	}
+	for _, item := range removed {
+		delta -= ps.itemScore(item, group.Name)
+	}
+	return delta
+}
+
+func (ps *groupPreferenceScorer) MaxPotentialScore(s *State) float64 {
+	// At best, every item still to be placed gets whichever of its choices scores best, if there's room for it or not
+	var potential float64
+	for _, item := range s.ItemsNotInGroups {
+		potential += ps.best[item]
+	}
+	return potential
+}
+
+// PreferenceCounts is how many items got each of their choices of group, see CountPreferences.
+type PreferenceCounts struct {
+	// ByRank[0] is how many items are in their first choice of group, ByRank[1] how many are in their second, and so on
+	// up to the longest list of choices
+	ByRank []int
+
+	// How many items with choices are in a group they didn't choose
+	Unranked int
+}
+
+// CountPreferences returns how many of the items in the groups got each of their choices for a RuleTypeGroupPreference
+// rule. Items without choices aren't counted.
+func CountPreferences(rule *Rule, groups []*Group) PreferenceCounts {
+	var counts PreferenceCounts
+	for _, group := range groups {
+		for _, item := range group.Items {
+			choices := splitList(item.Tags[rule.TagName])
+			for len(counts.ByRank) < len(choices) {
+				counts.ByRank = append(counts.ByRank, 0)
+			}
+			if len(choices) == 0 {
+				continue
+			}
+			if !containsString(choices, group.Name) {
+				counts.Unranked++
+				continue
+			}
+			for rank, name := range choices {
+				if name == group.Name {
+					counts.ByRank[rank]++
+					break
+				}
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,63 @@
+package arrange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestGroupPreference(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "ann", Tags: map[string]string{"sessions": "Pottery, Chess, Drama"}},
+		&Item{ID: "bob", Tags: map[string]string{"sessions": "Pottery, Drama, Chess"}},
+		&Item{ID: "cat", Tags: map[string]string{"sessions": "Pottery, Chess"}},
+		&Item{ID: "dan", Tags: map[string]string{"sessions": "Chess, Pottery"}},
+		&Item{ID: "eve", Tags: map[string]string{"sessions": "Drama"}},
+		&Item{ID: "fay", Tags: map[string]string{"sessions": ""}},
+	}
+	groups := []*Group{
+		&Group{Name: "Pottery", MinSize: 1, MaxSize: 2},
+		&Group{Name: "Chess", MinSize: 1, MaxSize: 2},
+		&Group{Name: "Drama", MinSize: 1, MaxSize: 2},
+	}
+	rules := []*Rule{
+		&Rule{TagName: "sessions", Type: RuleTypeGroupPreference, Weight: 1},
+	}
+
+	// Only two of the three who want Pottery most can have it. By default the choices get 3, 2 and 1 points.
+	result, err := Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{3 + 3 + 2 + 3 + 3}, result.Score)
+	assert.Equal(t, PreferenceCounts{ByRank: []int{4, 1, 0}}, CountPreferences(rules[0], result.Groups))
+
+	// With only first choices counting and a penalty for a group that wasn't chosen, whoever misses out on Pottery
+	// still gets a session they chose
+	rules[0].Params = map[string]string{"points": "1", "unranked": "-5"}
+	result, err = Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Score{4}, result.Score)
+	counts := CountPreferences(rules[0], result.Groups)
+	assert.Equal(t, 0, counts.Unranked)
+
+	rules[0].Params = map[string]string{"points": "3|two"}
+	_, err = Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, `bad configuration: GroupPreference rule for tag "sessions": points: "two" is not a number`, err.Error())
+}
+
+func TestGroupPreferenceMaxPotentialScore(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "ann", Tags: map[string]string{"sessions": "Pottery,Chess"}},
+		&Item{ID: "bob", Tags: map[string]string{"sessions": "Chess"}},
+		&Item{ID: "cat", Tags: map[string]string{}},
+	}
+	scorer, err := newGroupPreferenceScorer(&Rule{TagName: "sessions", Type: RuleTypeGroupPreference, Weight: -1,
+		Params: map[string]string{"unranked": "-1"}}, items)
+	assert.Equal(t, nil, err)
+
+	// With a negative weight, the best an item can do is the group it didn't rank
+	s := &State{Groups: []*Group{&Group{Name: "Chess", MaxSize: 3}}, ItemsNotInGroups: items}
+	assert.Equal(t, 2.0, scorer.MaxPotentialScore(s))
+	s.Groups[0].Items = items
+	assert.Equal(t, -1.0-2.0, scorer.ScoreGroup(s.Groups[0]))
+}
@@ -0,0 +1,303 @@
+package arrange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Problem is everything needed to compute an arrangement, as read from a single problem document.
+type Problem struct {
+	Items   []*Item
+	Rules   []*Rule
+	Groups  []*Group
+	Options Options
+}
+
+// The structure of a problem document, in JSON or YAML. See ProblemSchema for a description of each field.
+type problemDoc struct {
+	Items   []itemDoc   `json:"items" yaml:"items"`
+	Rules   []ruleDoc   `json:"rules" yaml:"rules"`
+	Groups  []groupDoc  `json:"groups" yaml:"groups"`
+	Options *optionsDoc `json:"options" yaml:"options"`
+}
+
+type itemDoc struct {
+	ID   string                 `json:"id" yaml:"id"`
+	Tags map[string]interface{} `json:"tags" yaml:"tags"`
+}
+
+type ruleDoc struct {
+	TagName  string            `json:"tagName" yaml:"tagName"`
+	Type     string            `json:"type" yaml:"type"`
+	Weight   *int              `json:"weight" yaml:"weight"`
+	Priority int               `json:"priority" yaml:"priority"`
+	Params   map[string]string `json:"params" yaml:"params"`
+}
+
+type groupDoc struct {
+	Name    string     `json:"name" yaml:"name"`
+	MinSize int        `json:"minSize" yaml:"minSize"`
+	MaxSize *int       `json:"maxSize" yaml:"maxSize"`
+	Quotas  []quotaDoc `json:"quotas" yaml:"quotas"`
+
+	Tags map[string]interface{} `json:"tags" yaml:"tags"`
+}
+
+type quotaDoc struct {
+	TagName string `json:"tagName" yaml:"tagName"`
+	Value   string `json:"value" yaml:"value"`
+	Min     int    `json:"min" yaml:"min"`
+	Max     *int   `json:"max" yaml:"max"`
+}
+
+type optionsDoc struct {
+	TimeoutSecs                float64    `json:"timeoutSecs" yaml:"timeoutSecs"`
+	Solver                     string     `json:"solver" yaml:"solver"`
+	TabuTenure                 int        `json:"tabuTenure" yaml:"tabuTenure"`
+	TabuPatience               int        `json:"tabuPatience" yaml:"tabuPatience"`
+	Seedings                   []string   `json:"seedings" yaml:"seedings"`
+	StopAfterRestarts          int        `json:"stopAfterRestarts" yaml:"stopAfterRestarts"`
+	StopAfterNoImprovementSecs float64    `json:"stopAfterNoImprovementSecs" yaml:"stopAfterNoImprovementSecs"`
+	TargetScore                []float64  `json:"targetScore" yaml:"targetScore"`
+	MaxStates                  int        `json:"maxStates" yaml:"maxStates"`
+	MaxVisitedStates           int        `json:"maxVisitedStates" yaml:"maxVisitedStates"`
+	BundleTag                  string     `json:"bundleTag" yaml:"bundleTag"`
+	PairHistory                [][]string `json:"pairHistory" yaml:"pairHistory"`
+	RepeatWeight               int        `json:"repeatWeight" yaml:"repeatWeight"`
+	RepeatPriority             int        `json:"repeatPriority" yaml:"repeatPriority"`
+}
+
+// ReadProblemFile reads a problem document from a JSON (.json) or YAML (.yaml or .yml) file.
+func ReadProblemFile(path string) (*Problem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReadProblemJSON(f, path)
+	case ".yaml", ".yml":
+		return ReadProblemYAML(f, path)
+	default:
+		return nil, fmt.Errorf("%s: unknown problem file type, expected .json, .yaml or .yml", path)
+	}
+}
+
+// ReadProblemJSON reads a problem document in JSON, as described by ProblemSchema. name is used to identify the file in
+// errors, which are LoadErrors.
+func ReadProblemJSON(r io.Reader, name string) (*Problem, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	var doc problemDoc
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, LoadErrors{&LoadError{File: name, Message: err.Error()}}
+	}
+	return doc.toProblem(name)
+}
+
+// ReadProblemYAML reads a problem document in YAML, with the same structure as the JSON described by ProblemSchema.
+// name is used to identify the file in errors, which are LoadErrors.
+func ReadProblemYAML(r io.Reader, name string) (*Problem, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc problemDoc
+	if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+		return nil, LoadErrors{&LoadError{File: name, Message: err.Error()}}
+	}
+	return doc.toProblem(name)
+}
+
+// toProblem converts and validates the document, reporting every problem found.
+func (doc *problemDoc) toProblem(name string) (*Problem, error) {
+	var errs LoadErrors
+	report := func(path, value, format string, args ...interface{}) {
+		errs = append(errs, &LoadError{File: name, Path: path, Value: value, Message: fmt.Sprintf(format, args...)})
+	}
+
+	p := &Problem{}
+
+	pathByID := map[string]string{}
+	for i, itemDoc := range doc.Items {
+		path := fmt.Sprintf("items[%d]", i)
+		if itemDoc.ID == "" {
+			report(path+".id", "", "is required")
+			continue
+		}
+		if prevPath, ok := pathByID[itemDoc.ID]; ok {
+			report(path+".id", itemDoc.ID, "is the same as %s", prevPath)
+			continue
+		}
+		pathByID[itemDoc.ID] = path
+
+		item := &Item{ID: itemDoc.ID, Tags: map[string]string{}}
+		tagNames := make([]string, 0, len(itemDoc.Tags))
+		for tagName := range itemDoc.Tags {
+			tagNames = append(tagNames, tagName)
+		}
+		sort.Strings(tagNames)
+		for _, tagName := range tagNames {
+			str, err := tagValueString(itemDoc.Tags[tagName])
+			if err != nil {
+				report(fmt.Sprintf("%s.tags.%s", path, tagName), "", "%v", err)
+				continue
+			}
+			item.Tags[tagName] = str
+		}
+		p.Items = append(p.Items, item)
+	}
+
+	for i, ruleDoc := range doc.Rules {
+		path := fmt.Sprintf("rules[%d]", i)
+		rule := &Rule{
+			TagName:  ruleDoc.TagName,
+			Type:     RuleType(ruleDoc.Type),
+			Priority: ruleDoc.Priority,
+			Params:   ruleDoc.Params,
+		}
+		if rule.TagName == "" {
+			report(path+".tagName", "", "is required")
+		}
+		if _, ok := getRuleScorerFactory(rule.Type); !ok {
+			report(path+".type", ruleDoc.Type, "unknown rule type, expected one of %s", ruleTypeList())
+		}
+		if rule.Priority >= constraintPriority {
+			report(path+".priority", strconv.Itoa(rule.Priority), "must be below %d", constraintPriority)
+		}
+		if ruleDoc.Weight == nil {
+			report(path+".weight", "", "is required")
+		} else {
+			rule.Weight = *ruleDoc.Weight
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+
+	for i, groupDoc := range doc.Groups {
+		path := fmt.Sprintf("groups[%d]", i)
+		group := &Group{Name: groupDoc.Name, MinSize: groupDoc.MinSize}
+		if len(groupDoc.Tags) > 0 {
+			group.Tags = map[string]string{}
+		}
+		tagNames := make([]string, 0, len(groupDoc.Tags))
+		for tagName := range groupDoc.Tags {
+			tagNames = append(tagNames, tagName)
+		}
+		sort.Strings(tagNames)
+		for _, tagName := range tagNames {
+			str, err := tagValueString(groupDoc.Tags[tagName])
+			if err != nil {
+				report(fmt.Sprintf("%s.tags.%s", path, tagName), "", "%v", err)
+				continue
+			}
+			group.Tags[tagName] = str
+		}
+		if groupDoc.MaxSize == nil {
+			report(path+".maxSize", "", "is required")
+		} else {
+			group.MaxSize = *groupDoc.MaxSize
+		}
+		if group.MinSize > group.MaxSize {
+			report(path+".minSize", strconv.Itoa(group.MinSize), "is more than maxSize")
+		}
+		for j, quotaDoc := range groupDoc.Quotas {
+			quotaPath := fmt.Sprintf("%s.quotas[%d]", path, j)
+			if quotaDoc.TagName == "" {
+				report(quotaPath+".tagName", "", "is required")
+			}
+			if quotaDoc.Max != nil && *quotaDoc.Max < quotaDoc.Min {
+				report(quotaPath+".max", strconv.Itoa(*quotaDoc.Max), "is less than min")
+			}
+			group.Quotas = append(group.Quotas, &Quota{
+				TagName: quotaDoc.TagName,
+				Value:   quotaDoc.Value,
+				Min:     quotaDoc.Min,
+				Max:     quotaDoc.Max,
+			})
+		}
+		p.Groups = append(p.Groups, group)
+	}
+
+	if doc.Options != nil {
+		p.Options.Timeout = time.Duration(doc.Options.TimeoutSecs * float64(time.Second))
+		p.Options.Solver = Solver(doc.Options.Solver)
+		p.Options.TabuTenure = doc.Options.TabuTenure
+		p.Options.TabuPatience = doc.Options.TabuPatience
+		for _, seeding := range doc.Options.Seedings {
+			p.Options.Seedings = append(p.Options.Seedings, Seeding(seeding))
+		}
+		p.Options.StopAfterRestarts = doc.Options.StopAfterRestarts
+		p.Options.StopAfterNoImprovement = time.Duration(doc.Options.StopAfterNoImprovementSecs * float64(time.Second))
+		if len(doc.Options.TargetScore) > 0 {
+			p.Options.TargetScore = Score(doc.Options.TargetScore)
+		}
+		p.Options.MaxStates = doc.Options.MaxStates
+		p.Options.MaxVisitedStates = doc.Options.MaxVisitedStates
+		p.Options.BundleTag = doc.Options.BundleTag
+		p.Options.RepeatWeight = doc.Options.RepeatWeight
+		p.Options.RepeatPriority = doc.Options.RepeatPriority
+		if p.Options.RepeatPriority >= constraintPriority {
+			report("options.repeatPriority", strconv.Itoa(p.Options.RepeatPriority), "must be below %d", constraintPriority)
+		}
+		if len(doc.Options.PairHistory) > 0 {
+			p.Options.PairHistory = NewPairHistory()
+			for _, ids := range doc.Options.PairHistory {
+				var items []*Item
+				for _, id := range ids {
+					items = append(items, &Item{ID: id})
+				}
+				p.Options.PairHistory.AddGroup(items)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+// tagValueString converts a tag value from a problem document to a string. Lists, like the IDs of related items, are
+// joined with commas.
+func tagValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, elem := range v {
+			if _, ok := elem.([]interface{}); ok {
+				return "", fmt.Errorf("lists of lists are not supported")
+			}
+			part, err := tagValueString(elem)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("must be a string, number, boolean or list of those")
+	}
+}
@@ -0,0 +1,123 @@
+package arrange
+
+// ProblemSchema is a JSON Schema describing problem documents (see ReadProblemJSON), which can be used to validate them
+// or to get completion in editors. YAML problem documents have the same structure.
+const ProblemSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "arrangeit problem",
+  "description": "Items to arrange, rules about their tags, groups to arrange them into, and solver options.",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["items", "rules", "groups"],
+  "properties": {
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["id"],
+        "properties": {
+          "id": {"type": "string", "minLength": 1, "description": "Unique ID of the item"},
+          "tags": {
+            "type": "object",
+            "description": "Tag names to tag values. Lists (e.g. IDs for a Relationship rule) are joined with commas.",
+            "additionalProperties": {
+              "oneOf": [
+                {"$ref": "#/definitions/scalar"},
+                {"type": "array", "items": {"$ref": "#/definitions/scalar"}}
+              ]
+            }
+          }
+        }
+      }
+    },
+    "rules": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["tagName", "type", "weight"],
+        "properties": {
+          "tagName": {"type": "string", "minLength": 1, "description": "Tag the rule applies to"},
+          "type": {"type": "string", "description": "Rule type, e.g. Sameness, Relationship, Nearness, GroupMatch, GroupEligibility or GroupPreference"},
+          "weight": {"type": "integer", "description": "Importance relative to other rules with the same priority"},
+          "priority": {"type": "integer", "default": 0, "description": "Higher priority rules are optimized first"},
+          "params": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      }
+    },
+    "groups": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["maxSize"],
+        "properties": {
+          "name": {"type": "string"},
+          "minSize": {"type": "integer", "minimum": 0, "default": 0},
+          "maxSize": {"type": "integer", "minimum": 0},
+          "tags": {
+            "type": "object",
+            "description": "Tag names to tag values, e.g. for GroupMatch and GroupEligibility rules. Lists are joined with commas.",
+            "additionalProperties": {
+              "oneOf": [
+                {"$ref": "#/definitions/scalar"},
+                {"type": "array", "items": {"$ref": "#/definitions/scalar"}}
+              ]
+            }
+          },
+          "quotas": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "additionalProperties": false,
+              "required": ["tagName", "value"],
+              "properties": {
+                "tagName": {"type": "string", "minLength": 1},
+                "value": {"type": "string"},
+                "min": {"type": "integer", "minimum": 0, "default": 0},
+                "max": {"type": "integer", "minimum": 0, "description": "Omit for no maximum"}
+              }
+            }
+          }
+        }
+      }
+    },
+    "options": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "timeoutSecs": {"type": "number", "minimum": 0, "description": "Return the best arrangement found after this long"},
+        "solver": {"enum": ["hill-climbing", "tabu"], "description": "The search algorithm to use"},
+        "tabuTenure": {"type": "integer", "minimum": 0, "description": "With the tabu solver, how many moves an item isn't moved back into the group it came from"},
+        "tabuPatience": {"type": "integer", "minimum": 0, "description": "With the tabu solver, stop after this many moves in a row without finding a better arrangement (default 1000)"},
+        "seedings": {
+          "type": "array",
+          "description": "How to make the arrangements the search starts from, used in turn along with random ones",
+          "items": {"enum": ["random", "nearness", "sameness", "relationships"]}
+        },
+        "stopAfterRestarts": {"type": "integer", "minimum": 0, "description": "Stop after this many restarts in a row without finding a better arrangement. The tabu solver doesn't restart, see tabuPatience"},
+        "stopAfterNoImprovementSecs": {"type": "number", "minimum": 0, "description": "Stop when no better arrangement has been found for this long"},
+        "targetScore": {
+          "type": "array",
+          "description": "Stop once an arrangement scores at least this well, with one number per rule priority, highest first",
+          "items": {"type": "number"}
+        },
+        "maxStates": {"type": "integer", "minimum": 0, "description": "Stop after exploring this many different states"},
+        "maxVisitedStates": {"type": "integer", "description": "How many explored states to remember so they aren't explored again; -1 for no limit"},
+        "bundleTag": {"type": "string", "description": "Tag whose items with the same value, like a family, are always put in the same group"},
+        "pairHistory": {
+          "type": "array",
+          "description": "Groups of item IDs that have been together in previous rounds",
+          "items": {"type": "array", "items": {"type": "string"}}
+        },
+        "repeatWeight": {"type": "integer", "description": "Score penalty for each repeat of a pairing in pairHistory"},
+        "repeatPriority": {"type": "integer", "description": "Rule priority the repeatWeight penalty is scored in"}
+      }
+    }
+  },
+  "definitions": {
+    "scalar": {"type": ["string", "number", "boolean", "null"]}
+  }
+}
+`
@@ -0,0 +1,122 @@
+package arrange
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestReadProblemJSON(t *testing.T) {
+	p, err := ReadProblemJSON(strings.NewReader(`{
+		"items": [
+			{"id": "bob", "tags": {"gender": "m", "age": 15, "friends": ["sue", "joe"]}},
+			{"id": "sue", "tags": {"gender": "f", "age": 16.5, "driver": true}}
+		],
+		"rules": [
+			{"tagName": "gender", "type": "Sameness", "weight": 2},
+			{"tagName": "friends", "type": "Relationship", "weight": 1, "priority": 1, "params": {"x": "y"}}
+		],
+		"groups": [
+			{"name": "Van", "maxSize": 7, "quotas": [{"tagName": "gender", "value": "f", "min": 1, "max": 3}]},
+			{"name": "Car", "minSize": 2, "maxSize": 4}
+		],
+		"options": {"timeoutSecs": 1.5, "repeatWeight": 2, "pairHistory": [["bob", "sue"]]}
+	}`), "problem.json")
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []*Item{
+		&Item{ID: "bob", Tags: map[string]string{"gender": "m", "age": "15", "friends": "sue,joe"}},
+		&Item{ID: "sue", Tags: map[string]string{"gender": "f", "age": "16.5", "driver": "true"}},
+	}, p.Items)
+	assert.Equal(t, []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2},
+		&Rule{TagName: "friends", Type: RuleTypeRelationship, Weight: 1, Priority: 1, Params: map[string]string{"x": "y"}},
+	}, p.Rules)
+	three := 3
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", MaxSize: 7, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Min: 1, Max: &three}}},
+		&Group{Name: "Car", MinSize: 2, MaxSize: 4},
+	}, p.Groups)
+	assert.Equal(t, 1500*time.Millisecond, p.Options.Timeout)
+	assert.Equal(t, 2, p.Options.RepeatWeight)
+	assert.Equal(t, 1, p.Options.PairHistory.Count("sue", "bob"))
+}
+
+func TestReadProblemYAML(t *testing.T) {
+	p, err := ReadProblemYAML(strings.NewReader(`
+items:
+  - id: bob
+    tags: {gender: m, age: 15, friends: [sue, joe]}
+  - id: sue
+    tags: {gender: f}
+rules:
+  - {tagName: gender, type: Sameness, weight: 2}
+groups:
+  - name: Van
+    maxSize: 7
+    quotas:
+      - {tagName: gender, value: f, max: 0}
+`), "problem.yaml")
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []*Item{
+		&Item{ID: "bob", Tags: map[string]string{"gender": "m", "age": "15", "friends": "sue,joe"}},
+		&Item{ID: "sue", Tags: map[string]string{"gender": "f"}},
+	}, p.Items)
+	assert.Equal(t, []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2}}, p.Rules)
+	zero := 0
+	assert.Equal(t, []*Group{
+		&Group{Name: "Van", MaxSize: 7, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Max: &zero}}},
+	}, p.Groups)
+}
+
+func TestReadProblemReportsEveryProblem(t *testing.T) {
+	_, err := ReadProblemJSON(strings.NewReader(`{
+		"items": [{"id": "bob"}, {"id": "bob"}, {"tags": {"x": [["y"]]}}],
+		"rules": [{"tagName": "gender", "type": "Samenes"}, {"tagName": "age", "type": "Sameness", "weight": 1, "priority": 2147483647}],
+		"groups": [{"name": "Van", "minSize": 3, "maxSize": 2, "quotas": [{"tagName": "", "value": "f", "min": 2, "max": 1}]}]
+	}`), "problem.json")
+	assert.Equal(t, strings.Join([]string{
+		`problem.json at items[1].id: "bob": is the same as items[0]`,
+		`problem.json at items[2].id: is required`,
+		`problem.json at rules[0].type: "Samenes": unknown rule type, expected one of ` + ruleTypeList(),
+		`problem.json at rules[0].weight: is required`,
+		`problem.json at rules[1].priority: "2147483647": must be below 2147483647`,
+		`problem.json at groups[0].minSize: "3": is more than maxSize`,
+		`problem.json at groups[0].quotas[0].tagName: is required`,
+		`problem.json at groups[0].quotas[0].max: "1": is less than min`,
+	}, "\n"), err.Error())
+
+	_, err = ReadProblemYAML(strings.NewReader("items: []\nextra: 1\n"), "problem.yaml")
+	assert.NotEqual(t, nil, err)
+	_, err = ReadProblemJSON(strings.NewReader(`{"extra": 1}`), "problem.json")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestQuotas(t *testing.T) {
+	// Without the quota, the strongest gender rule would put both girls together
+	one := 1
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "girl1"}, &Item{ID: "guy1"}}},
+			&Group{Items: []*Item{&Item{ID: "girl2"}, &Item{ID: "guy2"}}},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+				&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+				&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c2"}},
+				&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c2"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 10, Priority: 1},
+				&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 2, MaxSize: 2, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Max: &one}}},
+				&Group{Name: "Group 2", MinSize: 2, MaxSize: 2, Quotas: []*Quota{&Quota{TagName: "gender", Value: "f", Min: 1, Max: &one}}},
+			}),
+	)
+}
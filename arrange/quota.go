@@ -0,0 +1,52 @@
+package arrange
+
+// quotaScorer reduces the score by one for each item a group is short of a Quota's Min, or over its Max.
+type quotaScorer struct{}
+
+func (qs *quotaScorer) ScoreGroup(group *Group) float64 {
+	var violations int
+	for _, quota := range group.Quotas {
+		count := quota.count(group.Items)
+		if count < quota.Min {
+			violations += quota.Min - count
+		}
+		if quota.Max != nil && count > *quota.Max {
+			violations += count - *quota.Max
+		}
+	}
+	return -float64(violations)
+}
+
+func (qs *quotaScorer) MaxPotentialScore(s *State) float64 {
+	// Items still to be placed can only add to how far groups are over their maximums, but at best they make up for
+	// every shortfall
+	var shortfall int
+	for _, group := range s.Groups {
+		for _, quota := range group.Quotas {
+			if count := quota.count(group.Items); count < quota.Min {
+				shortfall += quota.Min - count
+			}
+		}
+	}
+	return float64(shortfall)
+}
+
+// count returns how many of the items have the quota's tag value.
+func (q *Quota) count(items []*Item) int {
+	var count int
+	for _, item := range items {
+		if item.Tags[q.TagName] == q.Value {
+			count++
+		}
+	}
+	return count
+}
+
+func hasQuotas(groups []*Group) bool {
+	for _, group := range groups {
+		if len(group.Quotas) > 0 {
+			return true
+		}
+	}
+	return false
+}
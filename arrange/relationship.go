@@ -0,0 +1,83 @@
+package arrange
+
+import (
+	"log"
+	"strings"
+)
+
+// relationshipScorer implements RuleTypeRelationship. The tag value of an item is a comma-separated list of the IDs of
+// other items it is related to, and the rule's weight is scored for each of those that end up in the same group.
+type relationshipScorer struct {
+	rule *Rule
+
+	// The items each item is related to. Items without relationships are left out.
+	related map[*Item][]*Item
+}
+
+func newRelationshipScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	itemsByID := make(map[string]*Item, len(items))
+	for _, item := range items {
+		itemsByID[item.ID] = item
+	}
+
+	rs := &relationshipScorer{rule: rule, related: map[*Item][]*Item{}}
+	for _, item := range items {
+		for _, id := range splitList(item.Tags[rule.TagName]) {
+			other, ok := itemsByID[id]
+			if !ok {
+				log.Printf("Item %q has %s %q, which is not the ID of an item being arranged", item.ID, rule.TagName, id)
+				continue
+			}
+			if other == item {
+				continue
+			}
+			rs.related[item] = append(rs.related[item], other)
+		}
+	}
+	return rs, nil
+}
+
+func (rs *relationshipScorer) ScoreGroup(group *Group) float64 {
+	var numTogether int
+	for _, item := range group.Items {
+		for _, other := range rs.related[item] {
+			if containsItem(group.Items, other) {
+				numTogether++
+			}
+		}
+	}
+	return float64(rs.rule.Weight * numTogether)
+}
+
+func (rs *relationshipScorer) MaxPotentialScore(s *State) float64 {
+	// If the rule weight is negative, the best we could theoretically do is keep everyone apart which adds nothing
+	if rs.rule.Weight < 0 {
+		return 0
+	}
+
+	// At best, every relationship involving an item that hasn't been placed yet is satisfied
+	notInGroups := map[*Item]bool{}
+	for _, item := range s.ItemsNotInGroups {
+		notInGroups[item] = true
+	}
+	var numPossible int
+	for item, others := range rs.related {
+		for _, other := range others {
+			if notInGroups[item] || notInGroups[other] {
+				numPossible++
+			}
+		}
+	}
+	return float64(rs.rule.Weight * numPossible)
+}
+
+// splitList splits a comma-separated tag value into its non-empty, trimmed parts.
+func splitList(val string) []string {
+	var parts []string
+	for _, part := range strings.Split(val, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
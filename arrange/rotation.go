@@ -0,0 +1,117 @@
+package arrange
+
+import (
+	"context"
+	"fmt"
+)
+
+// PairHistory counts how many times each pair of items has been placed in the same group, e.g. over the previous days
+// of a week-long rotation.
+type PairHistory struct {
+	counts map[itemPair]int
+}
+
+// itemPair is a pair of item IDs, always stored with the lower ID first so that (a, b) and (b, a) are the same pair.
+type itemPair struct {
+	id1 string
+	id2 string
+}
+
+func newItemPair(id1, id2 string) itemPair {
+	if id2 < id1 {
+		id1, id2 = id2, id1
+	}
+	return itemPair{id1, id2}
+}
+
+// NewPairHistory returns an empty PairHistory.
+func NewPairHistory() *PairHistory {
+	return &PairHistory{counts: map[itemPair]int{}}
+}
+
+// Add records that every pair of items in each of the given groups has been together once more.
+func (h *PairHistory) Add(groups []*Group) {
+	for _, group := range groups {
+		h.AddGroup(group.Items)
+	}
+}
+
+// AddGroup records that every pair of the given items has been together once more.
+func (h *PairHistory) AddGroup(items []*Item) {
+	for i := 0; i < len(items); i++ {
+		for j := i + 1; j < len(items); j++ {
+			h.counts[newItemPair(items[i].ID, items[j].ID)]++
+		}
+	}
+}
+
+// Count returns how many times the two items have been together.
+func (h *PairHistory) Count(id1, id2 string) int {
+	return h.counts[newItemPair(id1, id2)]
+}
+
+// groupRepeats sums up, over every pair of items in the group, how many times that pair has been together before.
+func (h *PairHistory) groupRepeats(group *Group) int {
+	var repeats int
+	for i := 0; i < len(group.Items); i++ {
+		for j := i + 1; j < len(group.Items); j++ {
+			repeats += h.counts[newItemPair(group.Items[i].ID, group.Items[j].ID)]
+		}
+	}
+	return repeats
+}
+
+// GetRotation produces a sequence of arrangements, e.g. one per day, where each round is scored by the rules as in
+// GetArrangement but is also penalized by opts.RepeatWeight for putting together pairs of items that were together in
+// previous rounds or in opts.PairHistory. opts.Timeout applies to each round separately.
+// It returns the result of every round along with the pair history including all of the rounds.
+func GetRotation(ctx context.Context, items []*Item, rules []*Rule, groups []*Group, rounds int, opts Options) ([]*Result, *PairHistory, error) {
+	history := NewPairHistory()
+	if opts.PairHistory != nil {
+		for pair, count := range opts.PairHistory.counts {
+			history.counts[pair] = count
+		}
+	}
+	opts.PairHistory = history
+
+	var results []*Result
+	for i := 0; i < rounds; i++ {
+		result, err := Arrange(ctx, items, rules, groups, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("round %d: %v", i+1, err)
+		}
+
+		history.Add(result.Groups)
+		results = append(results, result)
+	}
+	return results, history, nil
+}
+
+// repeatScorer reduces the score by weight for each time a pair of items in the same group have been together before.
+type repeatScorer struct {
+	history *PairHistory
+	weight  int
+}
+
+func (rs *repeatScorer) ScoreGroup(group *Group) float64 {
+	return -float64(rs.weight * rs.history.groupRepeats(group))
+}
+
+func (rs *repeatScorer) MaxPotentialScore(s *State) float64 {
+	// Adding items to groups can only add repeats, which only lowers the score unless repeats are being rewarded
+	if rs.weight >= 0 {
+		return 0
+	}
+
+	// At best, every pairing involving an item not placed yet is repeated
+	var repeats int
+	for pair, count := range rs.history.counts {
+		for _, item := range s.ItemsNotInGroups {
+			if item.ID == pair.id1 || item.ID == pair.id2 {
+				repeats += count
+				break
+			}
+		}
+	}
+	return -float64(rs.weight * repeats)
+}
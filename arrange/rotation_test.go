@@ -0,0 +1,64 @@
+package arrange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRotationAvoidsRepeatPairs(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "a", Tags: map[string]string{}},
+		&Item{ID: "b", Tags: map[string]string{}},
+		&Item{ID: "c", Tags: map[string]string{}},
+		&Item{ID: "d", Tags: map[string]string{}},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+	}
+
+	rounds, history, err := GetRotation(context.Background(), items, nil, groups, 3, Options{RepeatWeight: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(rounds))
+
+	// With 4 items in pairs over 3 rounds, everyone can meet everyone else exactly once
+	for i, item1 := range items {
+		for _, item2 := range items[i+1:] {
+			assert.Equal(t, 1, history.Count(item1.ID, item2.ID))
+		}
+	}
+}
+
+func TestRotationWithHistory(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "a", Tags: map[string]string{}},
+		&Item{ID: "b", Tags: map[string]string{}},
+		&Item{ID: "c", Tags: map[string]string{}},
+		&Item{ID: "d", Tags: map[string]string{}},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+	}
+
+	history := NewPairHistory()
+	history.AddGroup([]*Item{items[0], items[1]})
+	history.AddGroup([]*Item{items[2], items[3]})
+	history.AddGroup([]*Item{items[0], items[2]})
+	history.AddGroup([]*Item{items[1], items[3]})
+
+	rounds, _, err := GetRotation(context.Background(), items, nil, groups, 1, Options{PairHistory: history, RepeatWeight: 1})
+	assert.Equal(t, nil, err)
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "a"}, &Item{ID: "d"}}},
+			&Group{Items: []*Item{&Item{ID: "b"}, &Item{ID: "c"}}},
+		},
+		rounds[0].Groups,
+	)
+
+	// The history passed in should not have been modified
+	assert.Equal(t, 0, history.Count("a", "d"))
+}
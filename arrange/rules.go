@@ -0,0 +1,162 @@
+package arrange
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RuleScorer calculates the score for one Rule. The built-in rule types implement it, and more rule types can be added
+// with RegisterRuleType.
+//
+// A RuleScorer must only look at the items and groups it is given; the same RuleScorer is used to score many different
+// states.
+type RuleScorer interface {
+	// ScoreGroup returns how much the items currently in the group contribute to the score, already multiplied by the
+	// rule's weight. The score of a state is the sum of ScoreGroup over all its groups.
+	ScoreGroup(group *Group) float64
+
+	// MaxPotentialScore returns an upper bound on how much the sum of ScoreGroup over s.Groups could still increase
+	// once s.ItemsNotInGroups are placed into the groups. It must never be lower than what is actually achievable, or
+	// good states may be skipped. Returning 0 is fine for rules that can only lose score as items are added.
+	MaxPotentialScore(s *State) float64
+}
+
+// DeltaScorer may be implemented by a RuleScorer that can calculate the effect of changing a group's items more
+// cheaply than scoring the whole group again. It is used when trying out moves and swaps of items between groups.
+type DeltaScorer interface {
+	// ScoreGroupDelta returns how much ScoreGroup would change if the added items were put in the group and the
+	// removed items (which are all in the group) were taken out. The group itself must not be modified.
+	ScoreGroupDelta(group *Group, added, removed []*Item) float64
+}
+
+// RuleScorerFactory creates the RuleScorer for a rule, given every item that is going to be arranged. It returns an
+// error if the rule can't be used, e.g. if it is missing a parameter.
+type RuleScorerFactory func(rule *Rule, items []*Item) (RuleScorer, error)
+
+var ruleTypesMu sync.RWMutex
+var ruleTypes = map[RuleType]RuleScorerFactory{
+	RuleTypeSameness:     newSamenessScorer,
+	RuleTypeRelationship: newRelationshipScorer,
+	RuleTypeNearness:     newNearnessScorer,
+
+	RuleTypeGroupMatch:       newGroupMatchScorer,
+	RuleTypeGroupEligibility: newGroupEligibilityScorer,
+	RuleTypeGroupPreference:  newGroupPreferenceScorer,
+}
+
+// RegisterRuleType makes a new rule type available, so that rules with Type ruleType (including ones read from a
+// rules file) are scored by the RuleScorer that factory creates. It panics if the rule type is already registered.
+func RegisterRuleType(ruleType RuleType, factory RuleScorerFactory) {
+	ruleTypesMu.Lock()
+	defer ruleTypesMu.Unlock()
+	if _, ok := ruleTypes[ruleType]; ok {
+		panic(fmt.Sprintf("rule type %q is already registered", ruleType))
+	}
+	ruleTypes[ruleType] = factory
+}
+
+// RuleTypes returns every registered rule type, sorted by name.
+func RuleTypes() []RuleType {
+	ruleTypesMu.RLock()
+	defer ruleTypesMu.RUnlock()
+	var types []RuleType
+	for ruleType := range ruleTypes {
+		types = append(types, ruleType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func getRuleScorerFactory(ruleType RuleType) (RuleScorerFactory, bool) {
+	ruleTypesMu.RLock()
+	defer ruleTypesMu.RUnlock()
+	factory, ok := ruleTypes[ruleType]
+	return factory, ok
+}
+
+// tieredScorer is a RuleScorer along with the index in a Score that it adds to.
+type tieredScorer struct {
+	scorer RuleScorer
+	tier   int
+
+	// The rule being scored, or nil for other sources of score like quotas
+	rule *Rule
+
+	// Describes the source of the score, see RuleScore.Name
+	name string
+}
+
+// initScorers creates the scorers for every rule that has a weight. initTiers must have been called first.
+func (r *runner) initScorers() error {
+	r.scorers = nil
+	for _, rule := range r.rules {
+		if rule.Weight == 0 {
+			continue
+		}
+		factory, ok := getRuleScorerFactory(rule.Type)
+		if !ok {
+			return fmt.Errorf("bad configuration: unknown rule type %q for tag %q", rule.Type, rule.TagName)
+		}
+		scorer, err := factory(rule, r.items)
+		if err != nil {
+			return fmt.Errorf("bad configuration: %s rule for tag %q: %v", rule.Type, rule.TagName, err)
+		}
+		tier := r.tierByPriority[rule.Priority]
+		if rule.isConstraint() {
+			tier = r.tierByPriority[constraintPriority]
+		}
+		r.scorers = append(r.scorers, tieredScorer{
+			scorer: scorer,
+			tier:   tier,
+			rule:   rule,
+			name:   fmt.Sprintf("%s on %s", rule.Type, rule.TagName),
+		})
+	}
+
+	if r.opts.PairHistory != nil && r.opts.RepeatWeight != 0 {
+		r.scorers = append(r.scorers, tieredScorer{
+			scorer: &repeatScorer{r.opts.PairHistory, r.opts.RepeatWeight},
+			tier:   r.tierByPriority[r.opts.RepeatPriority],
+			name:   "Repeat pairings",
+		})
+	}
+
+	if hasQuotas(r.groups) {
+		r.scorers = append(r.scorers, tieredScorer{
+			scorer: &quotaScorer{},
+			tier:   r.tierByPriority[constraintPriority],
+			name:   "Quotas",
+		})
+	}
+	return nil
+}
+
+// groupScoreDelta returns how much the scorer's score for the group would change if the added items were put in it and
+// the removed items were taken out.
+func groupScoreDelta(scorer RuleScorer, group *Group, added, removed []*Item) float64 {
+	if len(added) == 0 && len(removed) == 0 {
+		return 0
+	}
+	if ds, ok := scorer.(DeltaScorer); ok {
+		return ds.ScoreGroupDelta(group, added, removed)
+	}
+
+	changed := group.emptyCopy(len(group.Items) + len(added))
+	for _, item := range group.Items {
+		if !containsItem(removed, item) {
+			changed.Items = append(changed.Items, item)
+		}
+	}
+	changed.Items = append(changed.Items, added...)
+	return scorer.ScoreGroup(changed) - scorer.ScoreGroup(group)
+}
+
+func containsItem(items []*Item, item *Item) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
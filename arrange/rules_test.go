@@ -0,0 +1,119 @@
+package arrange
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRelationship(t *testing.T) {
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "a"}, &Item{ID: "c"}}},
+			&Group{Items: []*Item{&Item{ID: "b"}, &Item{ID: "d"}}},
+		},
+		MustGetArrangement(
+			[]*Item{
+				&Item{ID: "a", Tags: map[string]string{"friends": "c"}},
+				&Item{ID: "b", Tags: map[string]string{"friends": ""}},
+				&Item{ID: "c", Tags: map[string]string{"friends": ""}},
+				&Item{ID: "d", Tags: map[string]string{"friends": "b, nobody"}},
+			},
+			[]*Rule{
+				&Rule{TagName: "friends", Type: RuleTypeRelationship, Weight: 1},
+			},
+			[]*Group{
+				&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+				&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+			}),
+	)
+}
+
+// sumLimitScorer is a custom rule that penalizes groups whose numeric tag values add up to more than a limit.
+type sumLimitScorer struct {
+	rule  *Rule
+	limit int
+}
+
+func (ss *sumLimitScorer) ScoreGroup(group *Group) float64 {
+	var sum int
+	for _, item := range group.Items {
+		n, _ := strconv.Atoi(item.Tags[ss.rule.TagName])
+		sum += n
+	}
+	if sum > ss.limit {
+		return -float64(ss.rule.Weight * (sum - ss.limit))
+	}
+	return 0
+}
+
+func (ss *sumLimitScorer) MaxPotentialScore(s *State) float64 {
+	return 0
+}
+
+func TestRegisterRuleType(t *testing.T) {
+	RegisterRuleType("SumLimit", func(rule *Rule, items []*Item) (RuleScorer, error) {
+		limit, err := strconv.Atoi(rule.Params["limit"])
+		if err != nil {
+			return nil, err
+		}
+		return &sumLimitScorer{rule: rule, limit: limit}, nil
+	})
+	assert.Equal(t, []RuleType{RuleTypeGroupEligibility, RuleTypeGroupMatch, RuleTypeGroupPreference, RuleTypeNearness,
+		RuleTypeRelationship, RuleTypeSameness, "SumLimit"}, RuleTypes())
+
+	items := []*Item{
+		&Item{ID: "big1", Tags: map[string]string{"luggage": "3"}},
+		&Item{ID: "big2", Tags: map[string]string{"luggage": "2"}},
+		&Item{ID: "small1", Tags: map[string]string{"luggage": "1"}},
+		&Item{ID: "small2", Tags: map[string]string{"luggage": "0"}},
+	}
+	groups := []*Group{
+		&Group{Name: "Car 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Car 2", MinSize: 2, MaxSize: 2},
+	}
+
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "big1"}, &Item{ID: "small2"}}},
+			&Group{Items: []*Item{&Item{ID: "big2"}, &Item{ID: "small1"}}},
+		},
+		MustGetArrangement(items,
+			[]*Rule{
+				&Rule{TagName: "luggage", Type: "SumLimit", Weight: 1, Params: map[string]string{"limit": "3"}},
+			},
+			groups),
+	)
+
+	_, err := GetArrangement(context.Background(), items,
+		[]*Rule{&Rule{TagName: "luggage", Type: "SumLimit", Weight: 1}},
+		groups)
+	assert.NotEqual(t, nil, err)
+
+	_, err = GetArrangement(context.Background(), items,
+		[]*Rule{&Rule{TagName: "luggage", Type: "Unknown", Weight: 1}},
+		groups)
+	assert.Equal(t, `bad configuration: unknown rule type "Unknown" for tag "luggage"`, err.Error())
+}
+
+func TestSamenessScoreGroupDelta(t *testing.T) {
+	rule := &Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2}
+	scorer, _ := newSamenessScorer(rule, nil)
+	guy1 := &Item{ID: "guy1", Tags: map[string]string{"gender": "m"}}
+	guy2 := &Item{ID: "guy2", Tags: map[string]string{"gender": "m"}}
+	girl1 := &Item{ID: "girl1", Tags: map[string]string{"gender": "f"}}
+	girl2 := &Item{ID: "girl2", Tags: map[string]string{"gender": "f"}}
+
+	before := &Group{Items: []*Item{guy1, girl1}}
+	after := &Group{Items: []*Item{guy1, guy2}}
+	assert.Equal(t,
+		scorer.ScoreGroup(after)-scorer.ScoreGroup(before),
+		scorer.(DeltaScorer).ScoreGroupDelta(before, []*Item{guy2}, []*Item{girl1}))
+
+	after = &Group{Items: []*Item{guy1, girl1, girl2}}
+	assert.Equal(t,
+		scorer.ScoreGroup(after)-scorer.ScoreGroup(before),
+		scorer.(DeltaScorer).ScoreGroupDelta(before, []*Item{girl2}, nil))
+}
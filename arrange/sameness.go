@@ -0,0 +1,155 @@
+package arrange
+
+import "math"
+
+// samenessScorer implements RuleTypeSameness.
+type samenessScorer struct {
+	rule *Rule
+}
+
+func newSamenessScorer(rule *Rule, items []*Item) (RuleScorer, error) {
+	return &samenessScorer{rule: rule}, nil
+}
+
+func (ss *samenessScorer) ScoreGroup(group *Group) float64 {
+	tagOccurrencesInGroup := map[string]int{}
+	for _, item := range group.Items {
+		val := item.Tags[ss.rule.TagName]
+		if val == "" {
+			continue
+		}
+		tagOccurrencesInGroup[val]++
+	}
+
+	var score float64
+	for _, count := range tagOccurrencesInGroup {
+		// Increase the score by count squared in order to prefer that many people with the same tag be together.
+		score += float64(ss.rule.Weight) * math.Pow(float64(count), 2)
+	}
+	return score
+}
+
+func (ss *samenessScorer) ScoreGroupDelta(group *Group, added, removed []*Item) float64 {
+	// Only the counts of the tag values being added or removed change, so only count those
+	changes := map[string]int{}
+	for _, item := range added {
+		if val := item.Tags[ss.rule.TagName]; val != "" {
+			changes[val]++
+		}
+	}
+	for _, item := range removed {
+		if val := item.Tags[ss.rule.TagName]; val != "" {
+			changes[val]--
+		}
+	}
+
+	counts := map[string]int{}
+	for _, item := range group.Items {
+		val := item.Tags[ss.rule.TagName]
+		if _, ok := changes[val]; ok {
+			counts[val]++
+		}
+	}
+
+	var delta float64
+	for val, change := range changes {
+		before := counts[val]
+		after := before + change
+		delta += float64(ss.rule.Weight) * float64(after*after-before*before)
+	}
+	return delta
+}
+
+func (ss *samenessScorer) MaxPotentialScore(s *State) float64 {
+	// Count how many items with each tag value are still to be placed, and how many of each each group already has
+	remaining := map[string]int{}
+	for _, item := range s.ItemsNotInGroups {
+		if val := item.Tags[ss.rule.TagName]; val != "" {
+			remaining[val]++
+		}
+	}
+	if len(remaining) == 0 {
+		return 0
+	}
+	counts := make([]map[string]int, len(s.Groups))
+	for i, group := range s.Groups {
+		counts[i] = map[string]int{}
+		for _, item := range group.Items {
+			if val := item.Tags[ss.rule.TagName]; remaining[val] > 0 {
+				counts[i][val]++
+			}
+		}
+	}
+
+	// Each value is bounded separately, as if it could use all of the space left in the groups
+	var increase float64
+	for val, count := range remaining {
+		if ss.rule.Weight > 0 {
+			increase += samenessMaxIncrease(s.Groups, counts, val, count)
+		} else {
+			increase += samenessMinIncrease(s.Groups, counts, val, count)
+		}
+	}
+	return float64(ss.rule.Weight) * increase
+}
+
+// samenessMaxIncrease returns an upper bound on how much the sum of the squares of the number of items with the value
+// in each group could go up by when count more of them are placed.
+func samenessMaxIncrease(groups []*Group, counts []map[string]int, val string, count int) float64 {
+	// At most, they all join the group that already has the most of them
+	var most, total, squares int
+	// And no group can end up with more than it has plus however many more fit in it, while the final sum of squares is
+	// at most the largest count times the total
+	var largestFinal int
+	for i, group := range groups {
+		have := counts[i][val]
+		total += have
+		squares += have * have
+		if have > most {
+			most = have
+		}
+		if final := have + minInt(count, group.MaxSize-len(group.Items)); final > largestFinal {
+			largestFinal = final
+		}
+	}
+	allInOne := (most+count)*(most+count) - most*most
+	byCapacity := largestFinal*(total+count) - squares
+	return float64(minInt(allInOne, byCapacity))
+}
+
+// samenessMinIncrease returns a lower bound on how much the sum of the squares of the number of items with the value in
+// each group must go up by when count more of them are placed.
+func samenessMinIncrease(groups []*Group, counts []map[string]int, val string, count int) float64 {
+	// The least is when they're spread as evenly as possible, so place them one at a time in whichever group with room
+	// has the fewest, which adds the least each time
+	have := make([]int, len(groups))
+	room := make([]int, len(groups))
+	for i, group := range groups {
+		have[i] = counts[i][val]
+		room[i] = group.MaxSize - len(group.Items)
+	}
+	var increase int
+	for ; count > 0; count-- {
+		best := -1
+		for i := range groups {
+			if room[i] > 0 && (best < 0 || have[i] < have[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			// There's no room left anywhere, which means there's no possible arrangement anyway
+			break
+		}
+		increase += 2*have[best] + 1
+		have[best]++
+		room[best]--
+	}
+	return float64(increase)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
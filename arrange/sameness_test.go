@@ -0,0 +1,101 @@
+package arrange
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// bestCompletion returns the highest total ScoreGroup of any way of placing the items not in groups yet, ignoring
+// MinSize.
+func bestCompletion(scorer RuleScorer, s *State) float64 {
+	best := -1e18
+	var place func(i int)
+	place = func(i int) {
+		if i == len(s.ItemsNotInGroups) {
+			var score float64
+			for _, group := range s.Groups {
+				score += scorer.ScoreGroup(group)
+			}
+			if score > best {
+				best = score
+			}
+			return
+		}
+		for _, group := range s.Groups {
+			if len(group.Items) < group.MaxSize {
+				group.Items = append(group.Items, s.ItemsNotInGroups[i])
+				place(i + 1)
+				group.Items = group.Items[:len(group.Items)-1]
+			}
+		}
+	}
+	place(0)
+	return best
+}
+
+func TestSamenessMaxPotentialScoreIsAnUpperBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		// A few items with a few values, some placed in groups already and some not
+		numItems := 1 + rng.Intn(7)
+		s := &State{}
+		var capacity int
+		for g := 0; g < 1+rng.Intn(3); g++ {
+			maxSize := 1 + rng.Intn(4)
+			capacity += maxSize
+			s.Groups = append(s.Groups, &Group{Name: fmt.Sprint(g), MaxSize: maxSize})
+		}
+		if numItems > capacity {
+			numItems = capacity
+		}
+		for i := 0; i < numItems; i++ {
+			item := &Item{ID: fmt.Sprint(i), Tags: map[string]string{"color": []string{"", "red", "green", "blue"}[rng.Intn(4)]}}
+			group := s.Groups[rng.Intn(len(s.Groups))]
+			if rng.Intn(2) == 0 && len(group.Items) < group.MaxSize {
+				group.Items = append(group.Items, item)
+			} else {
+				s.ItemsNotInGroups = append(s.ItemsNotInGroups, item)
+			}
+		}
+		// Make sure the rest can be placed
+		var room int
+		for _, group := range s.Groups {
+			room += group.MaxSize - len(group.Items)
+		}
+		if len(s.ItemsNotInGroups) > room {
+			continue
+		}
+
+		for _, weight := range []int{1, 3, -1, -2} {
+			scorer := &samenessScorer{rule: &Rule{TagName: "color", Type: RuleTypeSameness, Weight: weight}}
+			var current float64
+			for _, group := range s.Groups {
+				current += scorer.ScoreGroup(group)
+			}
+			bound := current + scorer.MaxPotentialScore(s)
+			if best := bestCompletion(scorer, s); bound < best {
+				t.Fatalf("weight %d: bound %g is below the best completion %g of %d placed and %d unplaced items",
+					weight, bound, best, numItems-len(s.ItemsNotInGroups), len(s.ItemsNotInGroups))
+			}
+		}
+	}
+}
+
+func TestSamenessMaxPotentialScoreIsTight(t *testing.T) {
+	// With nothing placed yet, the bounds are exact for evenly sized groups
+	var items []*Item
+	for i := 0; i < 6; i++ {
+		items = append(items, &Item{ID: fmt.Sprint(i), Tags: map[string]string{"gender": []string{"m", "f"}[i%2]}})
+	}
+	s := &State{
+		Groups:           []*Group{&Group{MaxSize: 3}, &Group{MaxSize: 3}},
+		ItemsNotInGroups: items,
+	}
+	for _, weight := range []int{1, -1} {
+		scorer := &samenessScorer{rule: &Rule{TagName: "gender", Type: RuleTypeSameness, Weight: weight}}
+		if bound, best := scorer.MaxPotentialScore(s), bestCompletion(scorer, s); bound != best {
+			t.Errorf("weight %d: bound %g, best %g", weight, bound, best)
+		}
+	}
+}
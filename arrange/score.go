@@ -0,0 +1,164 @@
+package arrange
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Score is how well a State follows the rules, with one entry per rule priority tier, highest priority first. Scores
+// are compared lexicographically, so a lower priority tier only matters when the higher ones are tied.
+type Score []float64
+
+// Better returns true if s is a strictly better score than other.
+func (s Score) Better(other Score) bool {
+	for i := range s {
+		if s[i] != other[i] {
+			return s[i] > other[i]
+		}
+	}
+	return false
+}
+
+// finite returns true if every tier of s is a number, and not NaN or infinite.
+func (s Score) finite() bool {
+	for _, tierScore := range s {
+		if math.IsNaN(tierScore) || math.IsInf(tierScore, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the score, separating tiers with slashes.
+func (s Score) String() string {
+	parts := make([]string, 0, len(s))
+	for _, tierScore := range s {
+		if tierScore == -math.MaxFloat64 {
+			parts = append(parts, "-inf")
+		} else {
+			parts = append(parts, fmt.Sprintf("%g", tierScore))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ParseScore parses a score written like Score.String does, e.g. "8/-2.5".
+func ParseScore(str string) (Score, error) {
+	var score Score
+	for _, part := range strings.Split(str, "/") {
+		part = strings.TrimSpace(part)
+		if part == "-inf" {
+			score = append(score, -math.MaxFloat64)
+			continue
+		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a score like 8/-2.5", str)
+		}
+		score = append(score, f)
+	}
+	return score, nil
+}
+
+// constraintPriority is the priority of the tier that constraints, like Quotas, are scored in. Rules must have lower
+// priorities, so that nothing outranks the constraints.
+const constraintPriority = math.MaxInt32
+
+// isConstraint returns whether the rule is scored in the constraints tier, regardless of its Priority.
+func (rule *Rule) isConstraint() bool {
+	return rule.Type == RuleTypeGroupEligibility
+}
+
+// initTiers figures out the priority tiers from the rules. Every distinct Rule.Priority becomes one tier, as does
+// Options.RepeatPriority if repeats are scored, with another tier above them all if there are constraints.
+func (r *runner) initTiers() {
+	var priorities []int
+	if r.opts.PairHistory != nil && r.opts.RepeatWeight != 0 {
+		priorities = append(priorities, r.opts.RepeatPriority)
+	}
+	hasConstraints := hasQuotas(r.groups)
+	for _, rule := range r.rules {
+		if rule.isConstraint() {
+			hasConstraints = hasConstraints || rule.Weight != 0
+			continue
+		}
+		priorities = append(priorities, rule.Priority)
+	}
+	if hasConstraints {
+		priorities = append(priorities, constraintPriority)
+	}
+	if len(priorities) == 0 {
+		// There's nothing to score, but a score still has one tier
+		priorities = append(priorities, 0)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	r.tierByPriority = map[int]int{}
+	for _, priority := range priorities {
+		if _, ok := r.tierByPriority[priority]; !ok {
+			r.tierByPriority[priority] = len(r.tierByPriority)
+		}
+	}
+}
+
+// newScore returns a zero score with an entry for every tier.
+func (r *runner) newScore() Score {
+	return make(Score, len(r.tierByPriority))
+}
+
+// worstScore returns the lowest possible score, used for states that don't meet the constraints.
+func (r *runner) worstScore() Score {
+	score := r.newScore()
+	for i := range score {
+		score[i] = -math.MaxFloat64
+	}
+	return score
+}
+
+// RuleScore is how much one rule, or another source of score like Quotas, contributed to the score of an arrangement.
+type RuleScore struct {
+	// The rule, or nil if the score came from something else
+	Rule *Rule
+
+	// Describes where the score came from, e.g. "Sameness on gender"
+	Name string
+
+	// Index of the tier within the Score that this contributes to
+	Tier int
+
+	Score float64
+}
+
+// ScoreArrangement scores an existing arrangement, e.g. one made by hand or an earlier result that has been edited,
+// as Arrange would. It returns the total score along with how much each rule contributed to it.
+func ScoreArrangement(rules []*Rule, groups []*Group, opts Options) (Score, []RuleScore, error) {
+	var items []*Item
+	for _, group := range groups {
+		items = append(items, group.Items...)
+	}
+
+	r := newRunner(context.Background(), items, rules, groups, opts)
+	r.initTiers()
+	if err := r.initScorers(); err != nil {
+		return nil, nil, err
+	}
+
+	return r.CalculateScore(&State{Groups: groups}), r.breakdown(groups), nil
+}
+
+// breakdown returns how much each scorer contributes to the score of the groups.
+func (r *runner) breakdown(groups []*Group) []RuleScore {
+	var breakdown []RuleScore
+	for _, ts := range r.scorers {
+		rs := RuleScore{Rule: ts.rule, Name: ts.name, Tier: ts.tier}
+		for _, group := range groups {
+			rs.Score += ts.scorer.ScoreGroup(group)
+		}
+		breakdown = append(breakdown, rs)
+	}
+	return breakdown
+}
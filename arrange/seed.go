@@ -0,0 +1,371 @@
+package arrange
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Seeding is a way of making the arrangements that a search starts from (see Options.Seedings).
+type Seeding string
+
+const (
+	// Scatter the items across the groups, trying every order of them in turn. This is the default.
+	SeedingRandom Seeding = "random"
+
+	// Cluster the items by the points of the strongest Nearness rule, with k-means limited by the group sizes
+	SeedingNearness Seeding = "nearness"
+
+	// Put items with the same value of the strongest Sameness rule's tag next to each other
+	SeedingSameness Seeding = "sameness"
+
+	// Put items that are linked by Relationship rules, directly or through others, next to each other
+	SeedingRelationships Seeding = "relationships"
+)
+
+// initSeedings works out the seedings to use in turn, checking they're all known.
+func (r *runner) initSeedings() error {
+	r.seedings = nil
+	hasRandom := false
+	for _, seeding := range r.opts.Seedings {
+		switch seeding {
+		case SeedingRandom:
+			hasRandom = true
+		case SeedingNearness, SeedingSameness, SeedingRelationships:
+		default:
+			return fmt.Errorf("bad configuration: unknown seeding %q", seeding)
+		}
+		r.seedings = append(r.seedings, seeding)
+	}
+	if !hasRandom {
+		// Random states are always tried too, so that the search still gets to everything eventually
+		r.seedings = append(r.seedings, SeedingRandom)
+	}
+	r.rng = rand.New(rand.NewSource(1))
+	return nil
+}
+
+// getStartingState returns a new state to start searching from, using each seeding in turn. It returns nil once every
+// random state has been tried.
+func (r *runner) getStartingState() *State {
+	seeding := r.seedings[r.seedTurn%len(r.seedings)]
+	r.seedTurn++
+
+	var s *State
+	switch seeding {
+	case SeedingNearness:
+		s = r.getNearnessState()
+	case SeedingSameness:
+		s = r.getSamenessState()
+	case SeedingRelationships:
+		s = r.getRelationshipsState()
+	}
+	if s == nil {
+		// Either it's SeedingRandom, or there's no rule to seed by
+		return r.getRandomState()
+	}
+	return s
+}
+
+// getWarmStartState returns the state that Options.Start describes, or nil if it isn't set or the items it doesn't
+// place can't be fitted around the ones it does. A bundle (see initBundles) goes where its first item is placed.
+func (r *runner) getWarmStartState() *State {
+	if r.opts.Start == nil {
+		return nil
+	}
+
+	s := &State{Groups: make([]*Group, 0, len(r.groups))}
+	groupsByName := map[string]*Group{}
+	for _, group := range r.groups {
+		copied := group.emptyCopy(len(r.items) / len(r.groups))
+		s.Groups = append(s.Groups, copied)
+		if _, ok := groupsByName[group.Name]; !ok {
+			groupsByName[group.Name] = copied
+		}
+	}
+	itemsByID := map[string]*Item{}
+	for _, item := range r.items {
+		itemsByID[item.ID] = item
+	}
+
+	placed := map[*Item]bool{}
+	for _, startGroup := range r.opts.Start {
+		group, ok := groupsByName[startGroup.Name]
+		if !ok {
+			continue
+		}
+		for _, startItem := range startGroup.Items {
+			item, ok := itemsByID[startItem.ID]
+			if !ok || placed[item] {
+				continue
+			}
+			unit := r.unitOf(item)
+			if len(group.Items)+len(unit) > group.MaxSize {
+				continue
+			}
+			group.Items = append(group.Items, unit...)
+			for _, unitItem := range unit {
+				placed[unitItem] = true
+			}
+		}
+	}
+
+	var rest []*Item
+	for _, item := range r.items {
+		if !placed[item] {
+			rest = append(rest, item)
+		}
+	}
+	if !placeUnits(s.Groups, r.unitsIn(rest)) {
+		return nil
+	}
+	s.Score = r.CalculateScore(s)
+	return s
+}
+
+// strongestRule returns the rule of the type with the highest priority, and the highest weight within that, or nil if
+// there are none with a positive weight.
+func (r *runner) strongestRule(ruleType RuleType) *Rule {
+	var strongest *Rule
+	for _, rule := range r.rules {
+		if rule.Type != ruleType || rule.Weight <= 0 {
+			continue
+		}
+		if strongest == nil || rule.Priority > strongest.Priority ||
+			(rule.Priority == strongest.Priority && rule.Weight > strongest.Weight) {
+			strongest = rule
+		}
+	}
+	return strongest
+}
+
+// groupSizes returns how many items each group gets in a starting state: at least its MinSize, with the rest spread
+// round-robin up to the MaxSizes, the same as getRandomState.
+func (r *runner) groupSizes() []int {
+	sizes := make([]int, len(r.groups))
+	left := len(r.items)
+	for i, group := range r.groups {
+		sizes[i] = minInt(group.MinSize, left)
+		left -= sizes[i]
+	}
+	for left > 0 {
+		for i, group := range r.groups {
+			if left > 0 && sizes[i] < group.MaxSize {
+				sizes[i]++
+				left--
+			}
+		}
+	}
+	return sizes
+}
+
+// stateFromOrder returns the state with the items filling up the groups in order, so that items next to each other in
+// the order mostly end up together. A bundle (see initBundles) goes where its first item would, if it fits. It returns
+// nil if the bundles can't all be fitted in.
+func (r *runner) stateFromOrder(order []*Item) *State {
+	sizes := r.groupSizes()
+	s := &State{Groups: make([]*Group, 0, len(r.groups))}
+	for i, size := range sizes {
+		s.Groups = append(s.Groups, r.groups[i].emptyCopy(size))
+	}
+	for _, unit := range r.unitsIn(order) {
+		// Put the unit in the first group that hasn't got its share yet, or failing that, any with room
+		target := -1
+		for i, group := range s.Groups {
+			if len(group.Items) < sizes[i] && len(group.Items)+len(unit) <= group.MaxSize {
+				target = i
+				break
+			}
+		}
+		for i, group := range s.Groups {
+			if target < 0 && len(group.Items)+len(unit) <= group.MaxSize {
+				target = i
+			}
+		}
+		if target < 0 {
+			return nil
+		}
+		s.Groups[target].Items = append(s.Groups[target].Items, unit...)
+	}
+	s.Score = r.CalculateScore(s)
+	return s
+}
+
+// getSamenessState groups the items by their value for the strongest Sameness rule, in a random order of the values.
+func (r *runner) getSamenessState() *State {
+	rule := r.strongestRule(RuleTypeSameness)
+	if rule == nil {
+		return nil
+	}
+	byValue := map[string][]*Item{}
+	var values []string
+	for _, item := range r.shuffledItems() {
+		val := item.Tags[rule.TagName]
+		if _, ok := byValue[val]; !ok {
+			values = append(values, val)
+		}
+		byValue[val] = append(byValue[val], item)
+	}
+
+	// Biggest values first, so they're less likely to be split up
+	sort.SliceStable(values, func(i, j int) bool { return len(byValue[values[i]]) > len(byValue[values[j]]) })
+	var order []*Item
+	for _, val := range values {
+		order = append(order, byValue[val]...)
+	}
+	return r.stateFromOrder(order)
+}
+
+// getRelationshipsState puts items that are related, directly or through other items, next to each other, in a random
+// order of the sets of related items.
+func (r *runner) getRelationshipsState() *State {
+	// Merge related items into sets
+	set := map[*Item]*Item{}
+	var find func(item *Item) *Item
+	find = func(item *Item) *Item {
+		if parent, ok := set[item]; ok && parent != item {
+			root := find(parent)
+			set[item] = root
+			return root
+		}
+		return item
+	}
+	found := false
+	for _, ts := range r.scorers {
+		rs, ok := ts.scorer.(*relationshipScorer)
+		if !ok || rs.rule.Weight <= 0 {
+			continue
+		}
+		for item, others := range rs.related {
+			for _, other := range others {
+				set[find(item)] = find(other)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	members := map[*Item][]*Item{}
+	var roots []*Item
+	for _, item := range r.shuffledItems() {
+		root := find(item)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], item)
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return len(members[roots[i]]) > len(members[roots[j]]) })
+	var order []*Item
+	for _, root := range roots {
+		order = append(order, members[root]...)
+	}
+	return r.stateFromOrder(order)
+}
+
+// getNearnessState clusters the items by the points of the strongest Nearness rule with k-means, where each group is a
+// cluster that can only take as many items as it has room for. It starts from random items as the centers.
+func (r *runner) getNearnessState() *State {
+	rule := r.strongestRule(RuleTypeNearness)
+	if rule == nil {
+		return nil
+	}
+	var ns *nearnessScorer
+	for _, ts := range r.scorers {
+		if ts.rule == rule {
+			ns, _ = ts.scorer.(*nearnessScorer)
+		}
+	}
+	if ns == nil || len(ns.points) == 0 {
+		return nil
+	}
+
+	sizes := r.groupSizes()
+	var withPoints, withoutPoints []*Item
+	for _, item := range r.shuffledItems() {
+		if _, ok := ns.points[item]; ok {
+			withPoints = append(withPoints, item)
+		} else {
+			withoutPoints = append(withoutPoints, item)
+		}
+	}
+
+	centers := make([]point, len(r.groups))
+	for i := range centers {
+		centers[i] = ns.points[withPoints[i%len(withPoints)]]
+	}
+
+	var assignment [][]*Item
+	for iteration := 0; iteration < 10; iteration++ {
+		assignment = assignToCenters(withPoints, ns.points, centers, sizes)
+
+		moved := false
+		for i, items := range assignment {
+			if len(items) == 0 {
+				continue
+			}
+			var center point
+			for _, item := range items {
+				center.x += ns.points[item].x
+				center.y += ns.points[item].y
+			}
+			center.x /= float64(len(items))
+			center.y /= float64(len(items))
+			if center != centers[i] {
+				centers[i] = center
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	// Items without points go wherever there's room
+	var order []*Item
+	for i, items := range assignment {
+		order = append(order, items...)
+		for n := len(items); n < sizes[i] && len(withoutPoints) > 0; n++ {
+			order = append(order, withoutPoints[0])
+			withoutPoints = withoutPoints[1:]
+		}
+	}
+	return r.stateFromOrder(order)
+}
+
+// assignToCenters assigns each item to a center, closest pairs first, with no more than sizes[i] items for center i.
+func assignToCenters(items []*Item, points map[*Item]point, centers []point, sizes []int) [][]*Item {
+	type candidate struct {
+		item     *Item
+		center   int
+		distance float64
+	}
+	candidates := make([]candidate, 0, len(items)*len(centers))
+	for _, item := range items {
+		p := points[item]
+		for i, c := range centers {
+			candidates = append(candidates, candidate{item, i, math.Hypot(p.x-c.x, p.y-c.y)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	assignment := make([][]*Item, len(centers))
+	assigned := map[*Item]bool{}
+	for _, c := range candidates {
+		if assigned[c.item] || len(assignment[c.center]) >= sizes[c.center] {
+			continue
+		}
+		assignment[c.center] = append(assignment[c.center], c.item)
+		assigned[c.item] = true
+	}
+	return assignment
+}
+
+// shuffledItems returns the items in a random order.
+func (r *runner) shuffledItems() []*Item {
+	items := append([]*Item(nil), r.items...)
+	r.rng.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	return items
+}
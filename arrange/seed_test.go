@@ -0,0 +1,99 @@
+package arrange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+// startingGroups returns the arrangement the search starts from with the seeding.
+func startingGroups(t *testing.T, items []*Item, rules []*Rule, groups []*Group, seeding Seeding) []*Group {
+	var first *Result
+	_, err := Arrange(context.Background(), items, rules, groups, Options{
+		Seedings:  []Seeding{seeding},
+		MaxStates: 1,
+		Progress: func(p Progress) {
+			if first == nil {
+				first = p.Best
+			}
+		},
+	})
+	assert.Equal(t, nil, err)
+	return first.Groups
+}
+
+func TestSeedings(t *testing.T) {
+	// The items alternate between two places, so a random start mixes them up
+	items := []*Item{
+		&Item{ID: "a1", Tags: map[string]string{"home": "0,0", "team": "a"}},
+		&Item{ID: "b1", Tags: map[string]string{"home": "100,100", "team": "b", "friend": "b3"}},
+		&Item{ID: "a2", Tags: map[string]string{"home": "0,1", "team": "a", "friend": "a1"}},
+		&Item{ID: "b2", Tags: map[string]string{"home": "100,101", "team": "b", "friend": "b1"}},
+		&Item{ID: "a3", Tags: map[string]string{"home": "1,0", "team": "a", "friend": "a2"}},
+		&Item{ID: "b3", Tags: map[string]string{"home": "101,100", "team": "b"}},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 3, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 3, MaxSize: 3},
+	}
+	exp := func() []*Group {
+		return []*Group{
+			&Group{Items: []*Item{&Item{ID: "a1"}, &Item{ID: "a2"}, &Item{ID: "a3"}}},
+			&Group{Items: []*Item{&Item{ID: "b1"}, &Item{ID: "b2"}, &Item{ID: "b3"}}},
+		}
+	}
+
+	random := startingGroups(t, items, []*Rule{&Rule{TagName: "team", Type: RuleTypeSameness, Weight: 1}}, groups, SeedingRandom)
+	assert.Equal(t, "b1", random[0].Items[1].ID)
+
+	assertArrangementsEqual(t, exp(), startingGroups(t, items,
+		[]*Rule{&Rule{TagName: "home", Type: RuleTypeNearness, Weight: 1}}, groups, SeedingNearness))
+	assertArrangementsEqual(t, exp(), startingGroups(t, items,
+		[]*Rule{&Rule{TagName: "team", Type: RuleTypeSameness, Weight: 1}}, groups, SeedingSameness))
+	assertArrangementsEqual(t, exp(), startingGroups(t, items,
+		[]*Rule{&Rule{TagName: "friend", Type: RuleTypeRelationship, Weight: 1}}, groups, SeedingRelationships))
+
+	_, err := Arrange(context.Background(), items, nil, groups, Options{Seedings: []Seeding{"alphabetical"}})
+	assert.Equal(t, `bad configuration: unknown seeding "alphabetical"`, err.Error())
+}
+
+func TestWarmStart(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "a1", Tags: map[string]string{"team": "a"}},
+		&Item{ID: "b1", Tags: map[string]string{"team": "b"}},
+		&Item{ID: "a2", Tags: map[string]string{"team": "a"}},
+		&Item{ID: "b2", Tags: map[string]string{"team": "b"}},
+		&Item{ID: "a3", Tags: map[string]string{"team": "a"}},
+		&Item{ID: "b3", Tags: map[string]string{"team": "b"}},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 3, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 3, MaxSize: 3},
+	}
+	// b3 isn't placed, and the Bus and x are no longer being arranged
+	start := []*Group{
+		&Group{Name: "Group 1", Items: []*Item{&Item{ID: "a1"}, &Item{ID: "b1"}, &Item{ID: "a2"}}},
+		&Group{Name: "Group 2", Items: []*Item{&Item{ID: "b2"}, &Item{ID: "a3"}}},
+		&Group{Name: "Bus", Items: []*Item{&Item{ID: "x"}}},
+	}
+
+	var first *Result
+	result, err := Arrange(context.Background(), items, []*Rule{&Rule{TagName: "team", Type: RuleTypeSameness, Weight: 1}},
+		groups, Options{
+			Start:     start,
+			MaxStates: 1,
+			Progress: func(p Progress) {
+				if first == nil {
+					first = p.Best
+				}
+			},
+		})
+	assert.Equal(t, nil, err)
+	assertArrangementsEqual(t, []*Group{
+		&Group{Items: []*Item{&Item{ID: "a1"}, &Item{ID: "b1"}, &Item{ID: "a2"}}},
+		&Group{Items: []*Item{&Item{ID: "b2"}, &Item{ID: "a3"}, &Item{ID: "b3"}}},
+	}, first.Groups)
+	assert.Equal(t, Score{10}, first.Score)
+	assert.T(t, !first.Score.Better(result.Score))
+}
@@ -0,0 +1,165 @@
+package arrange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GroupNameSortKey is a SortKey.TagName that sorts groups by their names, rather than by a tag of their items.
+const GroupNameSortKey = "Group"
+
+// SortKey is one level of a sort order for items or groups, like "staff and drivers before students".
+type SortKey struct {
+	TagName string
+
+	// Order lists tag values in the order they should be sorted. Values not in the list sort after the listed ones, in
+	// their natural order. If empty, all values sort in their natural order: numerically if both values are numbers,
+	// otherwise alphabetically.
+	Order []string
+
+	// Reverses the order
+	Descending bool
+}
+
+// ParseSortKeys parses a sort order like "role=staff|driver,-age": comma-separated keys, each a tag name, optionally
+// preceded by "-" to sort descending and followed by "=" and a "|"-separated list of values in the order to sort them.
+func ParseSortKeys(str string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var key SortKey
+		if strings.HasPrefix(part, "-") {
+			key.Descending = true
+			part = part[1:]
+		}
+		if i := strings.Index(part, "="); i >= 0 {
+			key.Order = strings.Split(part[i+1:], "|")
+			part = part[:i]
+		}
+		if part == "" {
+			return nil, fmt.Errorf("sort key is missing a tag name")
+		}
+		key.TagName = part
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// compare returns a negative number if value a sorts before b, positive if after, and 0 if they're equal, ignoring
+// Descending.
+func (key *SortKey) compare(a, b string) int {
+	if a == b {
+		return 0
+	}
+	rankA, rankB := key.rank(a), key.rank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+
+	numA, errA := strconv.ParseFloat(a, 64)
+	numB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// rank returns the index of the value in Order, or len(Order) if it isn't listed.
+func (key *SortKey) rank(value string) int {
+	for i, v := range key.Order {
+		if v == value {
+			return i
+		}
+	}
+	return len(key.Order)
+}
+
+// SortItems sorts the items by their tags according to the keys, in order. Items that compare equal keep their
+// original order.
+func SortItems(items []*Item, keys []SortKey) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, key := range keys {
+			c := key.compare(items[i].Tags[key.TagName], items[j].Tags[key.TagName])
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// SortGroups sorts the groups according to the keys, in order. For GroupNameSortKey the groups' names are compared.
+// Otherwise the values of the tag among each group's items are compared: the group with more items having the value
+// that sorts first comes first, and if they have the same number of those, the next value is compared, and so on. For
+// example, with "gender=m|f", the groups with the most boys come first, then among groups with the same number of boys,
+// those with the most girls. Groups that compare equal keep their original order.
+func SortGroups(groups []*Group, keys []SortKey) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, key := range keys {
+			c := key.compareGroups(groups[i], groups[j])
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+func (key *SortKey) compareGroups(g1, g2 *Group) int {
+	if key.TagName == GroupNameSortKey {
+		return key.compare(g1.Name, g2.Name)
+	}
+
+	counts1, counts2 := key.countValues(g1), key.countValues(g2)
+	var values []string
+	for value := range counts1 {
+		values = append(values, value)
+	}
+	for value := range counts2 {
+		if _, ok := counts1[value]; !ok {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return key.compare(values[i], values[j]) < 0 })
+
+	for _, value := range values {
+		if counts1[value] != counts2[value] {
+			// More of an earlier value sorts first
+			return counts2[value] - counts1[value]
+		}
+	}
+	return 0
+}
+
+func (key *SortKey) countValues(group *Group) map[string]int {
+	counts := map[string]int{}
+	for _, item := range group.Items {
+		counts[item.Tags[key.TagName]]++
+	}
+	return counts
+}
+
+// SortArrangement sorts the items within each group by itemKeys, then sorts the groups by groupKeys.
+func SortArrangement(groups []*Group, groupKeys, itemKeys []SortKey) {
+	for _, group := range groups {
+		SortItems(group.Items, itemKeys)
+	}
+	SortGroups(groups, groupKeys)
+}
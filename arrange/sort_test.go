@@ -0,0 +1,63 @@
+package arrange
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	keys, err := ParseSortKeys("role=staff|driver, -age,Group")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []SortKey{
+		{TagName: "role", Order: []string{"staff", "driver"}},
+		{TagName: "age", Descending: true},
+		{TagName: "Group"},
+	}, keys)
+
+	_, err = ParseSortKeys("role,=a|b")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestSortItems(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "sue", Tags: map[string]string{"role": "student", "age": "9"}},
+		&Item{ID: "bob", Tags: map[string]string{"role": "driver", "age": "40"}},
+		&Item{ID: "joe", Tags: map[string]string{"role": "student", "age": "10"}},
+		&Item{ID: "ann", Tags: map[string]string{"role": "staff", "age": "35"}},
+		&Item{ID: "tim", Tags: map[string]string{"role": "parent", "age": "38"}},
+	}
+	SortItems(items, []SortKey{
+		{TagName: "role", Order: []string{"staff", "driver"}},
+		{TagName: "age", Descending: true},
+	})
+
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	// Listed roles first, then the others alphabetically; ages compared as numbers
+	assert.Equal(t, []string{"ann", "bob", "tim", "joe", "sue"}, ids)
+}
+
+func TestSortGroups(t *testing.T) {
+	bro := &Item{Tags: map[string]string{"gender": "m"}}
+	sis := &Item{Tags: map[string]string{"gender": "f"}}
+	groups := []*Group{
+		&Group{Name: "A", Items: []*Item{sis, sis}},
+		&Group{Name: "B", Items: []*Item{bro, sis}},
+		&Group{Name: "C", Items: []*Item{bro, sis, sis}},
+		&Group{Name: "D", Items: []*Item{bro, bro}},
+	}
+	SortGroups(groups, []SortKey{{TagName: "gender", Order: []string{"m", "f"}}})
+
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	assert.Equal(t, []string{"D", "C", "B", "A"}, names)
+
+	SortGroups(groups, []SortKey{{TagName: GroupNameSortKey}})
+	assert.Equal(t, "A", groups[0].Name)
+	assert.Equal(t, "D", groups[3].Name)
+}
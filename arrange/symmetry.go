@@ -0,0 +1,93 @@
+package arrange
+
+import (
+	"sort"
+	"strings"
+)
+
+// initItemClasses works out which items are interchangeable: ones that every rule and quota treats the same, because
+// they have the same values for the tags that are scored. Swapping two such items doesn't change the score, so states
+// that only differ by that get the same digest, and moves between them aren't tried. initScorers and initBundles must
+// have been called first.
+//
+// Sameness, Nearness, GroupMatch and GroupEligibility rules and quotas only look at tag values. Relationships and pair
+// history look at which items are which, so the items involved in them, and items in bundles (see initBundles), are
+// only interchangeable with themselves. Other rule types could look at anything, so with any of them no items are
+// interchangeable.
+//
+// Groups with the same sizes, quotas and tags are interchangeable too, unless a GroupPreference rule, or another rule
+// type, could tell them apart by name.
+func (r *runner) initItemClasses() {
+	r.itemClasses = nil
+	r.namedGroups = false
+	tagNames := map[string]bool{}
+	unique := map[*Item]bool{}
+	for _, ts := range r.scorers {
+		switch scorer := ts.scorer.(type) {
+		case *samenessScorer, *nearnessScorer, *groupMatchScorer, *groupEligibilityScorer:
+			tagNames[ts.rule.TagName] = true
+		case *groupPreferenceScorer:
+			tagNames[ts.rule.TagName] = true
+			r.namedGroups = true
+		case *relationshipScorer:
+			for item, others := range scorer.related {
+				unique[item] = true
+				for _, other := range others {
+					unique[other] = true
+				}
+			}
+		case *repeatScorer:
+			ids := map[string]bool{}
+			for pair := range scorer.history.counts {
+				ids[pair.id1] = true
+				ids[pair.id2] = true
+			}
+			for _, item := range r.items {
+				if ids[item.ID] {
+					unique[item] = true
+				}
+			}
+		case *quotaScorer:
+			for _, group := range r.groups {
+				for _, quota := range group.Quotas {
+					tagNames[quota.TagName] = true
+				}
+			}
+		default:
+			r.namedGroups = true
+			return
+		}
+	}
+
+	for item := range r.bundleOf {
+		unique[item] = true
+	}
+
+	var sortedTagNames []string
+	for tagName := range tagNames {
+		sortedTagNames = append(sortedTagNames, tagName)
+	}
+	sort.Strings(sortedTagNames)
+
+	r.itemClasses = make(map[*Item]string, len(r.items))
+	for _, item := range r.items {
+		if unique[item] {
+			r.itemClasses[item] = "#" + item.ID
+			continue
+		}
+		values := make([]string, 0, len(sortedTagNames))
+		for _, tagName := range sortedTagNames {
+			values = append(values, item.Tags[tagName])
+		}
+		r.itemClasses[item] = "=" + strings.Join(values, "\x00")
+	}
+}
+
+// itemClass returns the class of the item in classes, or one based on its ID if it doesn't have one, so that it's only
+// interchangeable with itself.
+func itemClass(classes map[*Item]string, item *Item) string {
+	if class, ok := classes[item]; ok {
+		return class
+	}
+	return "#" + item.ID
+}
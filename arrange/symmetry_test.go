@@ -0,0 +1,48 @@
+package arrange
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestItemClasses(t *testing.T) {
+	guy1 := &Item{ID: "guy1", Tags: map[string]string{"gender": "m", "name": "Al", "friend": ""}}
+	guy2 := &Item{ID: "guy2", Tags: map[string]string{"gender": "m", "name": "Bo", "friend": ""}}
+	guy3 := &Item{ID: "guy3", Tags: map[string]string{"gender": "m", "name": "Cy", "friend": "girl1"}}
+	girl1 := &Item{ID: "girl1", Tags: map[string]string{"gender": "f", "name": "Di", "friend": ""}}
+	items := []*Item{guy1, guy2, guy3, girl1}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 2},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 2},
+	}
+
+	r := newTestRunner(t, items, []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 1},
+		&Rule{TagName: "friend", Type: RuleTypeRelationship, Weight: 1},
+		// Rules without a weight don't matter
+		&Rule{TagName: "name", Type: RuleTypeSameness},
+	}, groups, Options{})
+
+	// Only the guys without relationships are interchangeable
+	assert.Equal(t, r.itemClasses[guy1], r.itemClasses[guy2])
+	assert.NotEqual(t, r.itemClasses[guy1], r.itemClasses[guy3])
+	assert.NotEqual(t, r.itemClasses[guy1], r.itemClasses[girl1])
+
+	// So swapping them gives the same state, even with the groups in a different order
+	s1 := &State{Groups: []*Group{&Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy1, guy3}}, &Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy2, girl1}}}}
+	s2 := &State{Groups: []*Group{&Group{MinSize: 2, MaxSize: 2, Items: []*Item{girl1, guy1}}, &Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy3, guy2}}}}
+	s3 := &State{Groups: []*Group{&Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy1, guy2}}, &Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy3, girl1}}}}
+	assert.Equal(t, s1.digest(r.itemClasses, false), s2.digest(r.itemClasses, false))
+	assert.NotEqual(t, s1.digest(r.itemClasses, false), s3.digest(r.itemClasses, false))
+
+	// But groups of different sizes aren't interchangeable
+	s4 := &State{Groups: []*Group{&Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy1, guy3}}, &Group{MinSize: 2, MaxSize: 3, Items: []*Item{guy2, girl1}}}}
+	s5 := &State{Groups: []*Group{&Group{MinSize: 2, MaxSize: 2, Items: []*Item{guy2, girl1}}, &Group{MinSize: 2, MaxSize: 3, Items: []*Item{guy1, guy3}}}}
+	assert.NotEqual(t, s4.digest(r.itemClasses, false), s5.digest(r.itemClasses, false))
+
+	// Other rule types could look at anything, so nothing is interchangeable with them
+	r.scorers = append(r.scorers, tieredScorer{scorer: &sumLimitScorer{rule: r.rules[0], limit: 1}, rule: r.rules[0]})
+	r.initItemClasses()
+	assert.NotEqual(t, itemClass(r.itemClasses, guy1), itemClass(r.itemClasses, guy2))
+}
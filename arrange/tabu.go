@@ -0,0 +1,105 @@
+package arrange
+
+// DefaultTabuPatience is how many moves SolverTabu makes without finding a better arrangement before it stops, if
+// Options.TabuPatience isn't set.
+const DefaultTabuPatience = 1000
+
+// tabuSearch is SolverTabu. It starts from r.bestState and returns why it stopped.
+func (r *runner) tabuSearch() StopReason {
+	tenure := r.opts.TabuTenure
+	if tenure <= 0 {
+		tenure = len(r.items)/4 + 1
+	}
+	patience := r.opts.TabuPatience
+	if patience <= 0 {
+		patience = DefaultTabuPatience
+	}
+
+	tabu := newTabuList(r.itemClasses, tenure)
+	current := r.bestState
+	var movesWithoutImprovement int
+	for moveNum := 0; ; moveNum++ {
+		if stopReason := r.stopReason(); stopReason != "" {
+			return stopReason
+		}
+		if movesWithoutImprovement >= patience {
+			return StopReasonNoImprovement
+		}
+
+		digest := current.digest(r.itemClasses, r.namedGroups)
+		if !r.visited.contains(digest) {
+			r.visited.add(digest)
+		}
+		r.statesTried++
+
+		m := r.nextTabuMove(current, tabu, moveNum)
+		if m == nil {
+			// There's nothing that can be moved, e.g. because there's only one group
+			return StopReasonExhausted
+		}
+		tabu.add(m, moveNum)
+		current = r.applyMove(current, m)
+
+		if current.Score.Better(r.bestState.Score) {
+			movesWithoutImprovement = 0
+		} else {
+			movesWithoutImprovement++
+		}
+		r.improve(current)
+	}
+}
+
+// nextTabuMove returns the best move from current that the tabu list allows, or the best move of all if it allows
+// none, or nil if there are no moves.
+func (r *runner) nextTabuMove(current *State, tabu *tabuList, moveNum int) *move {
+	m := r.bestMove(current, func(m *move) bool {
+		// Aspiration: a tabu move is still allowed if it finds a better arrangement than any so far
+		return m.score.Better(r.bestState.Score) || !tabu.forbids(m, moveNum)
+	})
+	if m == nil {
+		// Every move is tabu, so make the best one anyway rather than getting stuck
+		m = r.bestMove(current, nil)
+	}
+	return m
+}
+
+// tabuList keeps SolverTabu from undoing its recent moves. Since interchangeable items (see initItemClasses) are only
+// tried once per class, it doesn't remember the items that were moved, but the class of each and the group it was
+// taken out of: putting any item of that class back into that group would undo the move.
+type tabuList struct {
+	classes map[*Item]string
+	tenure  int
+
+	// The move number until which an item of a class can't go into a group
+	until map[tabuKey]int
+}
+
+type tabuKey struct {
+	class  string
+	gIndex int
+}
+
+func newTabuList(classes map[*Item]string, tenure int) *tabuList {
+	return &tabuList{classes: classes, tenure: tenure, until: map[tabuKey]int{}}
+}
+
+// add makes undoing move number moveNum tabu for the next tenure moves.
+func (tl *tabuList) add(m *move, moveNum int) {
+	for _, c := range m.changes {
+		for _, item := range c.removed {
+			tl.until[tabuKey{itemClass(tl.classes, item), c.gIndex}] = moveNum + tl.tenure + 1
+		}
+	}
+}
+
+// forbids returns whether move number moveNum would undo any of the recent moves.
+func (tl *tabuList) forbids(m *move, moveNum int) bool {
+	for _, c := range m.changes {
+		for _, item := range c.added {
+			if tl.until[tabuKey{itemClass(tl.classes, item), c.gIndex}] > moveNum {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package arrange
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestTabuSearch(t *testing.T) {
+	items := []*Item{
+		&Item{ID: "guy1", Tags: map[string]string{"gender": "m", "church": "c1"}},
+		&Item{ID: "girl1", Tags: map[string]string{"gender": "f", "church": "c1"}},
+		&Item{ID: "guy2", Tags: map[string]string{"gender": "m", "church": "c1"}},
+		&Item{ID: "girl2", Tags: map[string]string{"gender": "f", "church": "c2"}},
+		&Item{ID: "guy3", Tags: map[string]string{"gender": "m", "church": "c2"}},
+		&Item{ID: "girl3", Tags: map[string]string{"gender": "f", "church": "c2"}},
+	}
+	rules := []*Rule{
+		&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2},
+		&Rule{TagName: "church", Type: RuleTypeSameness, Weight: 1},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 1, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 1, MaxSize: 3},
+	}
+
+	result, err := Arrange(context.Background(), items, rules, groups, Options{Solver: SolverTabu})
+	assert.Equal(t, nil, err)
+	assertArrangementsEqual(t,
+		[]*Group{
+			&Group{Items: []*Item{&Item{ID: "girl3"}, &Item{ID: "girl1"}, &Item{ID: "girl2"}}},
+			&Group{Items: []*Item{&Item{ID: "guy3"}, &Item{ID: "guy2"}, &Item{ID: "guy1"}}},
+		},
+		result.Groups)
+
+	// The church rule can't be fully satisfied, so it doesn't reach the bound and runs out of patience
+	assert.Equal(t, StopReasonNoImprovement, result.StopReason)
+
+	result, err = Arrange(context.Background(), items, rules, groups, Options{Solver: SolverTabu, TabuPatience: 5})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, StopReasonNoImprovement, result.StopReason)
+
+	_, err = Arrange(context.Background(), items, rules, groups, Options{Solver: "annealing"})
+	assert.Equal(t, `bad configuration: unknown solver "annealing"`, err.Error())
+}
+
+func TestTabuSearchMatchesHillClimbing(t *testing.T) {
+	// Both solvers should find the best arrangement of a small problem, which hill climbing does by trying everything
+	var items []*Item
+	for i := 0; i < 8; i++ {
+		items = append(items, &Item{ID: fmt.Sprintf("item%d", i), Tags: map[string]string{
+			"color": []string{"red", "green", "blue"}[i%3],
+			"size":  []string{"s", "l"}[i%2],
+		}})
+	}
+	rules := []*Rule{
+		&Rule{TagName: "color", Type: RuleTypeSameness, Weight: 3},
+		&Rule{TagName: "size", Type: RuleTypeSameness, Weight: -1},
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 2, MaxSize: 3},
+		&Group{Name: "Group 2", MinSize: 2, MaxSize: 3},
+		&Group{Name: "Group 3", MinSize: 2, MaxSize: 3},
+	}
+
+	hillClimbing, err := Arrange(context.Background(), items, rules, groups, Options{})
+	assert.Equal(t, nil, err)
+	tabu, err := Arrange(context.Background(), items, rules, groups, Options{Solver: SolverTabu})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, hillClimbing.Score, tabu.Score)
+}
+
+func TestTabuSearchDoesNotUndoMoves(t *testing.T) {
+	// The items of each color are interchangeable, so only one of them is tried as the one to move, and it mustn't
+	// matter which one was moved last
+	var items []*Item
+	for i := 0; i < 12; i++ {
+		items = append(items, &Item{ID: fmt.Sprintf("item%d", i), Tags: map[string]string{
+			"color": []string{"red", "green", "blue"}[i%3],
+		}})
+	}
+	groups := []*Group{
+		&Group{Name: "Group 1", MinSize: 3, MaxSize: 5},
+		&Group{Name: "Group 2", MinSize: 3, MaxSize: 5},
+		&Group{Name: "Group 3", MinSize: 3, MaxSize: 5},
+	}
+	r := newTestRunner(t, items, []*Rule{
+		&Rule{TagName: "color", Type: RuleTypeSameness, Weight: 1},
+	}, groups, Options{})
+
+	// Start from the best arrangement, so that every move makes it worse and the search has to wander
+	s := &State{}
+	for g, group := range groups {
+		s.Groups = append(s.Groups, group.emptyCopy(0))
+		for i := g; i < len(items); i += 3 {
+			s.Groups[g].Items = append(s.Groups[g].Items, items[i])
+		}
+	}
+	s.Score = r.CalculateScore(s)
+	r.bestState = s
+
+	const tenure = 3
+	tabu := newTabuList(r.itemClasses, tenure)
+	recent := []stateDigest{s.digest(r.itemClasses, r.namedGroups)}
+	for moveNum := 0; moveNum < 20; moveNum++ {
+		m := r.nextTabuMove(s, tabu, moveNum)
+		tabu.add(m, moveNum)
+		s = r.applyMove(s, m)
+
+		digest := s.digest(r.itemClasses, r.namedGroups)
+		for _, d := range recent {
+			assert.NotEqual(t, d, digest, moveNum)
+		}
+		recent = append(recent, digest)
+		if len(recent) > tenure {
+			recent = recent[1:]
+		}
+	}
+}
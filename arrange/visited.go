@@ -0,0 +1,64 @@
+package arrange
+
+// DefaultMaxVisitedStates is how many explored states a search remembers if Options.MaxVisitedStates isn't set. At
+// about visitedStateBytes each, that's around 50MB.
+const DefaultMaxVisitedStates = 1 << 20
+
+// visitedStateBytes is roughly how much memory remembering one state takes: its digest in both the map and the ring,
+// plus the map's overhead.
+const visitedStateBytes = 48
+
+// stateDigest identifies a state, see State.digest. It's 128 bits so that two different states are very unlikely to
+// get the same digest, which would make the search skip one of them as if it had already been explored.
+type stateDigest [16]byte
+
+// visitedStates remembers the digests of the states that have been explored, so they aren't explored again. It holds at
+// most limit of them, forgetting the oldest when it's full, so that long searches don't keep using more memory. A
+// forgotten state may be explored again, which only wastes some time.
+type visitedStates struct {
+	limit   int
+	digests map[stateDigest]struct{}
+
+	// The digests in the order they were added. Once the limit is reached it's used as a ring, with next being the
+	// oldest.
+	order []stateDigest
+	next  int
+}
+
+// newVisitedStates returns an empty set holding at most limit states, or any number if limit is negative.
+func newVisitedStates(limit int) *visitedStates {
+	if limit == 0 {
+		limit = DefaultMaxVisitedStates
+	}
+	return &visitedStates{limit: limit, digests: map[stateDigest]struct{}{}}
+}
+
+func (v *visitedStates) contains(d stateDigest) bool {
+	_, ok := v.digests[d]
+	return ok
+}
+
+// add remembers d, forgetting the oldest state if the set is full. d must not already be in the set.
+func (v *visitedStates) add(d stateDigest) {
+	if v.limit < 0 {
+		v.digests[d] = struct{}{}
+		return
+	}
+	if len(v.order) < v.limit {
+		v.order = append(v.order, d)
+	} else {
+		delete(v.digests, v.order[v.next])
+		v.order[v.next] = d
+		v.next = (v.next + 1) % v.limit
+	}
+	v.digests[d] = struct{}{}
+}
+
+func (v *visitedStates) len() int {
+	return len(v.digests)
+}
+
+// memoryUsage estimates how many bytes the set is using.
+func (v *visitedStates) memoryUsage() int64 {
+	return int64(v.len()) * visitedStateBytes
+}
@@ -0,0 +1,49 @@
+package arrange
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestVisitedStatesForgetsOldest(t *testing.T) {
+	v := newVisitedStates(2)
+	d1, d2, d3 := stateDigest{1}, stateDigest{2}, stateDigest{3}
+	v.add(d1)
+	v.add(d2)
+	assert.T(t, v.contains(d1))
+	assert.T(t, v.contains(d2))
+
+	v.add(d3)
+	assert.T(t, !v.contains(d1))
+	assert.T(t, v.contains(d2))
+	assert.T(t, v.contains(d3))
+	assert.Equal(t, 2, v.len())
+	assert.Equal(t, int64(2*visitedStateBytes), v.memoryUsage())
+
+	v.add(d1)
+	assert.T(t, !v.contains(d2))
+	assert.T(t, v.contains(d1))
+
+	unlimited := newVisitedStates(-1)
+	for i := 0; i < 10; i++ {
+		unlimited.add(stateDigest{byte(i)})
+	}
+	assert.Equal(t, 10, unlimited.len())
+}
+
+func TestStateDigest(t *testing.T) {
+	a, b, c := &Item{ID: "a"}, &Item{ID: "b"}, &Item{ID: "c"}
+	ab, bc := &Item{ID: "ab"}, &Item{ID: "bc"}
+
+	// Neither the order of the items nor of the groups matters
+	s1 := &State{Groups: []*Group{&Group{Items: []*Item{a, b}}, &Group{Items: []*Item{c}}}}
+	s2 := &State{Groups: []*Group{&Group{Items: []*Item{c}}, &Group{Items: []*Item{b, a}}}}
+	assert.Equal(t, s1.digest(nil, false), s2.digest(nil, false))
+
+	s3 := &State{Groups: []*Group{&Group{Items: []*Item{a, c}}, &Group{Items: []*Item{b}}}}
+	assert.NotEqual(t, s1.digest(nil, false), s3.digest(nil, false))
+
+	// IDs run together don't look the same
+	assert.NotEqual(t, (&Group{Items: []*Item{ab, c}}).digest(nil, false), (&Group{Items: []*Item{a, bc}}).digest(nil, false))
+}
@@ -0,0 +1,156 @@
+package arrange
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dankinder/arrangeit/internal/xlsx"
+)
+
+// Workbook is an Excel (.xlsx) workbook with items, rules, groups, pair history or an arrangement in its sheets, each
+// laid out the same as the CSV files.
+type Workbook struct {
+	// How the sheets are read. Comma isn't used.
+	Options CSVOptions
+
+	name   string
+	sheets []*xlsx.Sheet
+}
+
+// OpenWorkbook reads the workbook at path.
+func OpenWorkbook(path string) (*Workbook, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadWorkbook(f, info.Size(), path)
+}
+
+// ReadWorkbook reads a workbook of the given size. name is used to identify the file in errors, which are LoadErrors.
+func ReadWorkbook(r io.ReaderAt, size int64, name string) (*Workbook, error) {
+	sheets, err := xlsx.Read(r, size)
+	if err != nil {
+		return nil, LoadErrors{&LoadError{File: name, Message: err.Error()}}
+	}
+	return &Workbook{name: name, sheets: sheets}, nil
+}
+
+// SheetNames returns the names of the sheets in the workbook, in order.
+func (wb *Workbook) SheetNames() []string {
+	var names []string
+	for _, sheet := range wb.sheets {
+		names = append(names, sheet.Name)
+	}
+	return names
+}
+
+// ReadItems reads items from the named sheet, laid out like ReadItemsCSV. If sheet is "", the sheet named "Items" is
+// used, or the only sheet if there's just one.
+func (wb *Workbook) ReadItems(sheet string) ([]*Item, error) {
+	recs, errs := wb.records(sheet, "Items")
+	items := readItems(recs, &errs)
+	return items, errs.err()
+}
+
+// ReadRules reads rules from the named sheet, laid out like ReadRulesCSV. If sheet is "", the sheet named "Rules" is
+// used, or the only sheet if there's just one.
+func (wb *Workbook) ReadRules(sheet string) ([]*Rule, error) {
+	recs, errs := wb.records(sheet, "Rules")
+	rules := readRules(recs, &errs)
+	return rules, errs.err()
+}
+
+// ReadGroups reads groups from the named sheet, laid out like ReadGroupsCSV. If sheet is "", the sheet named "Groups"
+// is used, or the only sheet if there's just one.
+func (wb *Workbook) ReadGroups(sheet string) ([]*Group, error) {
+	recs, errs := wb.records(sheet, "Groups")
+	groups := readGroups(recs, &errs)
+	return groups, errs.err()
+}
+
+// ReadPairHistory reads pairings from previous rounds from the named sheet, laid out like ReadPairHistoryCSV. If sheet
+// is "", the sheet named "History" is used, or the only sheet if there's just one.
+func (wb *Workbook) ReadPairHistory(sheet string) (*PairHistory, error) {
+	recs, errs := wb.records(sheet, "History")
+	history := readPairHistory(recs, &errs)
+	return history, errs.err()
+}
+
+// ReadArrangement reads an arrangement from the named sheet, laid out like ReadArrangementCSV. If sheet is "", the
+// sheet named "Arrangement" is used, or the only sheet if there's just one.
+func (wb *Workbook) ReadArrangement(sheet string) ([]*Group, error) {
+	recs, errs := wb.records(sheet, "Arrangement")
+	groups := readArrangement(recs, &errs)
+	return groups, errs.err()
+}
+
+// records finds the sheet and reads its records. The first non-empty row is the header, and empty rows are skipped.
+func (wb *Workbook) records(sheetName, defaultName string) (*records, LoadErrors) {
+	name := sheetName
+	if name == "" {
+		name = defaultName
+	}
+	var sheet *xlsx.Sheet
+	for _, s := range wb.sheets {
+		if s.Name == name {
+			sheet = s
+		}
+	}
+	if sheet == nil && sheetName == "" && len(wb.sheets) == 1 {
+		sheet = wb.sheets[0]
+	}
+
+	recs := &records{file: fmt.Sprintf("%s sheet %s", wb.name, name), opts: wb.Options}
+	if sheet == nil {
+		return recs, LoadErrors{&LoadError{File: wb.name,
+			Message: fmt.Sprintf("no sheet named %q, expected one of %s", name, strings.Join(wb.SheetNames(), ", "))}}
+	}
+	recs.file = fmt.Sprintf("%s sheet %s", wb.name, sheet.Name)
+
+	var errs LoadErrors
+	for i, values := range sheet.Rows {
+		values = trimEmptyCells(values)
+		if len(values) == 0 {
+			continue
+		}
+		// Copy them, since they may be trimmed
+		values = append([]string(nil), values...)
+		recs.trimSpace(values)
+		row := i + 1
+		if recs.header == nil {
+			recs.header = values
+			recs.headerRow = row
+			continue
+		}
+		if len(values) > len(recs.header) {
+			errs = append(errs, &LoadError{File: recs.file, Row: row,
+				Message: fmt.Sprintf("has %d columns but the header has %d", len(values), len(recs.header))})
+			continue
+		}
+		// Unlike CSV, empty cells at the end of a row aren't stored
+		for len(values) < len(recs.header) {
+			values = append(values, "")
+		}
+		recs.rows = append(recs.rows, record{row: row, values: values})
+	}
+
+	if recs.header == nil {
+		errs = append(errs, &LoadError{File: recs.file, Message: "at least a header row is required"})
+	}
+	return recs, errs
+}
+
+// trimEmptyCells removes the empty cells from the end of a row, which spreadsheets often have from formatting.
+func trimEmptyCells(values []string) []string {
+	for len(values) > 0 && strings.TrimSpace(values[len(values)-1]) == "" {
+		values = values[:len(values)-1]
+	}
+	return values
+}
@@ -0,0 +1,76 @@
+package arrange
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/dankinder/arrangeit/internal/xlsx"
+)
+
+func testWorkbook(t *testing.T, sheets map[string][][]interface{}, order ...string) *Workbook {
+	var buf bytes.Buffer
+	w := xlsx.NewWriter(&buf)
+	for _, name := range order {
+		assert.Equal(t, nil, w.AddSheet(name, sheets[name]))
+	}
+	assert.Equal(t, nil, w.Close())
+
+	wb, err := ReadWorkbook(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "signups.xlsx")
+	assert.Equal(t, nil, err)
+	return wb
+}
+
+func TestWorkbook(t *testing.T) {
+	wb := testWorkbook(t, map[string][][]interface{}{
+		"Items": {
+			{"Name", "gender", "age", ""},
+			{"bob", "m", 15},
+			nil,
+			{"sue", "f", 16, ""},
+		},
+		"Rules": {
+			{"TagName", "RuleType", "Weight"},
+			{"gender", "Sameness", 2},
+		},
+		"Cars": {
+			{"GroupName", "MaxSize"},
+			{"Van", 7},
+		},
+	}, "Items", "Rules", "Cars")
+	assert.Equal(t, []string{"Items", "Rules", "Cars"}, wb.SheetNames())
+
+	items, err := wb.ReadItems("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Item{
+		&Item{ID: "bob", Tags: map[string]string{"gender": "m", "age": "15"}},
+		&Item{ID: "sue", Tags: map[string]string{"gender": "f", "age": "16"}},
+	}, items)
+
+	rules, err := wb.ReadRules("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Rule{&Rule{TagName: "gender", Type: RuleTypeSameness, Weight: 2}}, rules)
+
+	groups, err := wb.ReadGroups("Cars")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Group{&Group{Name: "Van", MaxSize: 7}}, groups)
+
+	_, err = wb.ReadGroups("")
+	assert.Equal(t, `signups.xlsx: no sheet named "Groups", expected one of Items, Rules, Cars`, err.Error())
+}
+
+func TestWorkbookReportsRowNumbers(t *testing.T) {
+	wb := testWorkbook(t, map[string][][]interface{}{
+		"Signups": {
+			nil,
+			{"Name", "gender"},
+			{"bob", "m", "extra"},
+			{"", "f"},
+		},
+	}, "Signups")
+
+	// The only sheet is used whatever its name
+	_, err := wb.ReadItems("")
+	assert.Equal(t, `signups.xlsx sheet Signups row 3: has 3 columns but the header has 2`+"\n"+
+		`signups.xlsx sheet Signups row 4 column "Name": ID is empty`, err.Error())
+}
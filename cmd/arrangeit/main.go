@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dankinder/arrangeit/arrange"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+var problemFile string
+var printProblemSchema bool
+
+var itemsFile string
+var rulesFile string
+var groupsFile string
+
+var minGroupSize int
+var maxGroupSize int
+var maxNumGroups int
+
+var timeoutSeconds int
+var solver string
+var tabuTenure int
+var tabuPatience int
+var seedings string
+var stopAfterRestarts int
+var stopAfterSeconds int
+var targetScore string
+var maxStates int
+var maxVisitedStates int
+var bundleTag string
+var startFile string
+
+var numRounds int
+var historyFile string
+var repeatWeight int
+var repeatPriority int
+
+var outputFormat string
+var outFile string
+var scoreFile string
+var exportLPFile string
+var lpSolutionFile string
+
+var delimiter string
+var idColumn string
+var ignoreColumns string
+var trimSpace bool
+var showNormalization bool
+var showProgress bool
+var sortGroups string
+var sortItems string
+var columns string
+var rosterTemplatePath string
+
+func init() {
+	flag.StringVar(&problemFile, "problem", "", "path to a JSON or YAML problem file with the items, rules, groups and options, instead of -items, -rules and -groups")
+	flag.BoolVar(&printProblemSchema, "problem-schema", false, "print the JSON Schema for -problem files and exit")
+	flag.StringVar(&itemsFile, "items", "", "path to the items to arrange, as CSV or an Excel sheet given as file.xlsx#Sheet (the sheet defaults to Items)")
+	flag.StringVar(&rulesFile, "rules", "", "path to the rules, as CSV or an Excel sheet (defaults to the Rules sheet)")
+	flag.StringVar(&groupsFile, "groups", "", "path to the list of groups, as CSV or an Excel sheet (defaults to the Groups sheet)")
+	flag.IntVar(&minGroupSize, "min-size", 0, "minimum size of a group")
+	flag.IntVar(&maxGroupSize, "max-size", 0, "maximum size of a group")
+	flag.IntVar(&maxNumGroups, "max-groups", 0, "maximum number of groups")
+	flag.IntVar(&timeoutSeconds, "timeout-secs", 0, "after this many seconds, return the best arrangement found so far (per round when using -rounds)")
+	flag.StringVar(&solver, "solver", string(arrange.SolverHillClimbing), "search algorithm, one of: hill-climbing, tabu")
+	flag.IntVar(&tabuTenure, "tabu-tenure", 0, "with -solver tabu, how many moves an item isn't moved back into the group it came from (default based on the number of items)")
+	flag.IntVar(&tabuPatience, "tabu-patience", 0, fmt.Sprintf("with -solver tabu, stop after this many moves in a row without finding a better arrangement (default %d)", arrange.DefaultTabuPatience))
+	flag.StringVar(&seedings, "seeding", "", "comma-separated ways of making the arrangements the search starts from, used in turn along with random ones: random, nearness (cluster by location), sameness, relationships")
+	flag.IntVar(&stopAfterRestarts, "stop-after-restarts", 0, "stop after restarting the search this many times in a row without finding a better arrangement (see -tabu-patience for -solver tabu)")
+	flag.IntVar(&stopAfterSeconds, "stop-after-secs", 0, "stop when no better arrangement has been found for this many seconds")
+	flag.StringVar(&targetScore, "target-score", "", "stop once an arrangement scores at least this well, written like the scores in the output, e.g. \"0/12\" with one number per rule priority")
+	flag.IntVar(&maxStates, "max-states", 0, "stop after exploring this many different arrangements")
+	flag.IntVar(&maxVisitedStates, "max-visited-states", 0, "how many explored arrangements to remember so they aren't explored again, at roughly 48 bytes each; older ones are forgotten (default 1048576, -1 for no limit)")
+	flag.StringVar(&startFile, "start", "", "path to an existing arrangement to start the search from, e.g. a previous result to improve on after changing the input (read like -score)")
+	flag.StringVar(&bundleTag, "bundle-tag", "", "tag whose items with the same value, like a family or carpool, are always put in the same group")
+	flag.IntVar(&numRounds, "rounds", 1, "number of rounds to arrange, each avoiding pairs that were together in previous rounds")
+	flag.StringVar(&historyFile, "history", "", "path to pairings from previous rounds (columns Round, GroupName, ItemID), as CSV or an Excel sheet (defaults to the History sheet)")
+	flag.IntVar(&repeatWeight, "repeat-weight", 1, "score penalty for each time a pair of items has been together in a previous round")
+	flag.IntVar(&repeatPriority, "repeat-priority", 0, "rule priority tier in which the -repeat-weight penalty is scored")
+	flag.StringVar(&outputFormat, "output-format", formatTable, "output format, one of: "+strings.Join(outputFormats, ", "))
+	flag.StringVar(&outFile, "out", "", "path to write the output to instead of stdout; without -output-format, the extension picks the format (.json, .csv, .md, .html or .xlsx)")
+	flag.StringVar(&sortGroups, "sort-groups", "", "how to order the groups in the output, e.g. \"gender=m|f\" to put the groups with the most m, then the most f, first; or \"Group\" to sort by name (see -sort-items for the syntax)")
+	flag.StringVar(&sortItems, "sort-items", "", "how to order the items within each group in the output, as comma-separated tag names, each optionally preceded by - to sort descending and followed by =value1|value2|... to give the order of the values; e.g. \"role=staff|driver,-age\"")
+	flag.StringVar(&columns, "columns", "", "comma-separated tags to include in the output, instead of the ones the rules use (or every tag, for JSON and CSV)")
+	flag.StringVar(&rosterTemplatePath, "roster-template", "", "path to a Go template (text/template, or html/template for roster-html) to use for -output-format roster or roster-html instead of the default")
+	flag.StringVar(&delimiter, "delimiter", "", "field delimiter of the CSV files: a character, or tab, comma, semicolon or pipe; by default whichever of comma, semicolon or tab the header row has most of")
+	flag.StringVar(&idColumn, "id-column", "", "name of the column of -items with the item IDs, instead of the first column")
+	flag.StringVar(&ignoreColumns, "ignore-columns", "", "comma-separated columns of -items that aren't tags, e.g. contact details")
+	flag.BoolVar(&trimSpace, "trim-space", false, "remove whitespace from the start and end of every value in the input files")
+	flag.BoolVar(&showNormalization, "normalization-report", false, "print each distinct raw value of the tags normalized by the rules' trim, fold, map, mapFile and buckets params, and what it became, to stderr")
+	flag.BoolVar(&showProgress, "progress", true, "print a line to stderr each time a better arrangement is found")
+	flag.StringVar(&exportLPFile, "export-lp", "", "path to write the problem to as an integer program in CPLEX LP format, for solving exactly with e.g. CBC or HiGHS, instead of arranging")
+	flag.StringVar(&lpSolutionFile, "lp-solution", "", "path to a solver's solution to the model written by -export-lp for the same input, to output instead of arranging")
+	flag.StringVar(&scoreFile, "score", "", "path to an existing arrangement to score instead of arranging (columns Group, Item and any tags, as written by -output-format csv, or the Arrangement sheet of -output-format xlsx)")
+}
+
+// TODO better help text
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+
+	flag.Parse()
+	if printProblemSchema {
+		fmt.Print(arrange.ProblemSchema)
+		return
+	}
+
+	outputFormatSet := false
+	flag.Visit(func(f *flag.Flag) {
+		outputFormatSet = outputFormatSet || f.Name == "output-format"
+	})
+	if !outputFormatSet {
+		if format := formatForFile(outFile); format != "" {
+			outputFormat = format
+		}
+	}
+	if !containsString(outputFormats, outputFormat) {
+		fmt.Printf("-output-format must be one of: %s\n", strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
+	if _, err := parseDelimiter(delimiter); err != nil {
+		fmt.Printf("bad -delimiter: %v\n", err)
+		os.Exit(1)
+	}
+	if outputFormat == formatXLSX && outFile == "" {
+		fmt.Println("-out is required with -output-format xlsx")
+		os.Exit(1)
+	}
+
+	if scoreFile != "" {
+		if problemFile == "" && rulesFile == "" {
+			fmt.Println("-problem or -rules is required with -score")
+			os.Exit(1)
+		}
+	} else if problemFile == "" {
+		if itemsFile == "" || rulesFile == "" {
+			fmt.Println("-problem, or -items and -rules, are required")
+			os.Exit(1)
+		}
+
+		if groupsFile == "" && (maxGroupSize == 0 && maxNumGroups == 0) {
+			fmt.Println("either -groups or -max-size and -max-groups are required")
+			os.Exit(1)
+		}
+	}
+
+	problem := loadInput()
+
+	pprofPath := os.Getenv("CPU_PROFILE_PATH")
+	if pprofPath != "" {
+		f, err := os.Create(pprofPath)
+		if err != nil {
+			log.Fatal("could not create CPU profile: ", err)
+		}
+		defer f.Close() // error handling omitted for example
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatal("could not start CPU profile: ", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	if scoreFile != "" {
+		writeOutput(problem, []*arrange.Result{scoreArrangement(problem)}, nil)
+		return
+	}
+
+	if exportLPFile != "" {
+		exportLP(problem)
+		return
+	}
+	if lpSolutionFile != "" {
+		writeOutput(problem, []*arrange.Result{readLPSolution(problem)}, nil)
+		return
+	}
+
+	if numRounds > 1 || historyFile != "" {
+		rounds, history := runRotation(problem)
+		writeOutput(problem, rounds, history)
+		return
+	}
+
+	result, err := arrange.Arrange(context.Background(), problem.Items, problem.Rules, problem.Groups, problem.Options)
+	if err != nil {
+		fmt.Printf("error computing arrangement: %v\n", err)
+		os.Exit(1)
+	}
+	if showProgress {
+		fmt.Fprintf(os.Stderr, "Stopped: %s\n", result.StopReason)
+	}
+	writeOutput(problem, []*arrange.Result{result}, nil)
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func runRotation(problem *arrange.Problem) ([]*arrange.Result, *arrange.PairHistory) {
+	rounds, history, err := arrange.GetRotation(context.Background(), problem.Items, problem.Rules, problem.Groups, numRounds, problem.Options)
+	if err != nil {
+		fmt.Printf("error computing rotation: %v\n", err)
+		os.Exit(1)
+	}
+	return rounds, history
+}
+
+// scoreArrangement scores the arrangement in -score without changing it. Group sizes come from -groups if given.
+func scoreArrangement(problem *arrange.Problem) *arrange.Result {
+	var groups []*arrange.Group
+	err := readInputFile(scoreFile, func(r io.Reader, name string) (err error) {
+		groups, err = csvOptions().ReadArrangement(r, name)
+		return err
+	}, func(wb *arrange.Workbook, sheet string) (err error) {
+		groups, err = wb.ReadArrangement(sheet)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("problems with the input:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	for _, group := range groups {
+		for _, sized := range problem.Groups {
+			if sized.Name == group.Name {
+				group.MinSize, group.MaxSize, group.Quotas, group.Tags = sized.MinSize, sized.MaxSize, sized.Quotas, sized.Tags
+			}
+		}
+		if group.MaxSize == 0 {
+			group.MaxSize = len(group.Items)
+		}
+	}
+
+	var items []*arrange.Item
+	for _, group := range groups {
+		items = append(items, group.Items...)
+	}
+	normalizeItems(items, problem.Rules)
+
+	score, breakdown, err := arrange.ScoreArrangement(problem.Rules, groups, problem.Options)
+	if err != nil {
+		fmt.Printf("error scoring arrangement: %v\n", err)
+		os.Exit(1)
+	}
+	return &arrange.Result{Groups: groups, Score: score, Breakdown: breakdown}
+}
+
+// exportLP writes the problem to -export-lp as an integer program.
+func exportLP(problem *arrange.Problem) {
+	f, err := os.Create(exportLPFile)
+	if err != nil {
+		fmt.Printf("error writing model: %v\n", err)
+		os.Exit(1)
+	}
+	err = arrange.WriteLP(f, problem.Items, problem.Rules, problem.Groups, problem.Options)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("error writing model: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// readLPSolution reads the arrangement in -lp-solution and scores it.
+func readLPSolution(problem *arrange.Problem) *arrange.Result {
+	f, err := os.Open(lpSolutionFile)
+	if err != nil {
+		fmt.Printf("error reading solution: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	groups, err := arrange.ReadLPSolution(f, problem.Items, problem.Groups)
+	if err != nil {
+		fmt.Printf("error reading solution: %v\n", err)
+		os.Exit(1)
+	}
+
+	score, breakdown, err := arrange.ScoreArrangement(problem.Rules, groups, problem.Options)
+	if err != nil {
+		fmt.Printf("error scoring arrangement: %v\n", err)
+		os.Exit(1)
+	}
+	return &arrange.Result{Groups: groups, Score: score, Breakdown: breakdown}
+}
+
+// writeOutput sorts the results as requested and writes them in -output-format to -out, or stdout.
+func writeOutput(problem *arrange.Problem, results []*arrange.Result, history *arrange.PairHistory) {
+	groupKeys, err := arrange.ParseSortKeys(sortGroups)
+	if err != nil {
+		fmt.Printf("bad -sort-groups: %v\n", err)
+		os.Exit(1)
+	}
+	itemKeys, err := arrange.ParseSortKeys(sortItems)
+	if err != nil {
+		fmt.Printf("bad -sort-items: %v\n", err)
+		os.Exit(1)
+	}
+	for _, result := range results {
+		arrange.SortArrangement(result.Groups, groupKeys, itemKeys)
+	}
+
+	opts := outputOptions{Format: outputFormat, RosterTemplate: rosterTemplatePath}
+	for _, column := range strings.Split(columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			opts.Columns = append(opts.Columns, column)
+		}
+	}
+
+	w := os.Stdout
+	if outFile != "" {
+		f, err := os.Create(outFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeResults(w, opts, problem, results, history); err != nil {
+		fmt.Printf("error writing output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// normalizeItems cleans up the items' tags as configured in the rules, printing what was changed if
+// -normalization-report is given.
+func normalizeItems(items []*arrange.Item, rules []*arrange.Rule) {
+	norms, err := arrange.NormalizationsFromRules(rules)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report := arrange.NormalizeItems(items, norms)
+	if !showNormalization {
+		return
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Tag", "Raw value", "Normalized", "Items"})
+	for _, m := range report {
+		tw.AppendRow(table.Row{m.TagName, fmt.Sprintf("%q", m.Raw), fmt.Sprintf("%q", m.Normalized), m.Count})
+	}
+	fmt.Fprintln(os.Stderr, tw.Render())
+}
+
+func printProgress(p arrange.Progress) {
+	fmt.Fprintf(os.Stderr, "%7.1fs  score %s  (%d states tried, %d restarts, %.1fMB remembering %d states)\n",
+		p.Elapsed.Seconds(), p.Best.Score, p.StatesTried, p.Restarts, float64(p.VisitedMemory)/(1<<20), p.Visited)
+}
+
+// workbooks caches the .xlsx files that have been read, since items, rules and groups may all be in the same one.
+var workbooks = map[string]*arrange.Workbook{}
+
+// readInputFile reads a CSV file with readCSV, or a sheet of an Excel workbook with readSheet. Workbooks are given as
+// "path.xlsx", to use the default sheet name, or "path.xlsx#Sheet name".
+func readInputFile(path string, readCSV func(r io.Reader, name string) error, readSheet func(wb *arrange.Workbook, sheet string) error) error {
+	file, sheet := path, ""
+	if i := strings.LastIndex(path, "#"); i >= 0 && strings.EqualFold(filepath.Ext(path[:i]), ".xlsx") {
+		file, sheet = path[:i], path[i+1:]
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".xlsx") {
+		wb, ok := workbooks[file]
+		if !ok {
+			var err error
+			if wb, err = arrange.OpenWorkbook(file); err != nil {
+				return err
+			}
+			workbooks[file] = wb
+		}
+		wb.Options = csvOptions()
+		return readSheet(wb, sheet)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readCSV(f, path)
+}
+
+// csvOptions returns how to read the input files, according to the flags.
+func csvOptions() arrange.CSVOptions {
+	opts := arrange.CSVOptions{IDColumn: idColumn, TrimSpace: trimSpace}
+	// main has already checked it
+	opts.Comma, _ = parseDelimiter(delimiter)
+	for _, column := range strings.Split(ignoreColumns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			opts.IgnoreColumns = append(opts.IgnoreColumns, column)
+		}
+	}
+	return opts
+}
+
+// parseDelimiter returns the character that -delimiter gives, or names as tab, comma, semicolon or pipe. It returns 0,
+// to work out the delimiter from the header row, if it's empty.
+func parseDelimiter(str string) (rune, error) {
+	switch strings.ToLower(str) {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	if utf8.RuneCountInString(str) != 1 {
+		return 0, fmt.Errorf("%q should be a single character, or tab, comma, semicolon or pipe", str)
+	}
+	r, _ := utf8.DecodeRuneInString(str)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("%q can't be used as a delimiter", str)
+	}
+	return r, nil
+}
+
+// loadInput reads the problem file, or the items, rules, groups and history files, and any -start arrangement, given on
+// the command line. Solver options given as flags override any in the problem file. If the input can't be used, it
+// exits with every problem found in any of the files.
+func loadInput() *arrange.Problem {
+	var errs []error
+	load := func(path string, readCSV func(r io.Reader, name string) error, readSheet func(wb *arrange.Workbook, sheet string) error) {
+		if err := readInputFile(path, readCSV, readSheet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	problem := &arrange.Problem{}
+	if problemFile != "" {
+		var err error
+		if problem, err = arrange.ReadProblemFile(problemFile); err != nil {
+			errs = append(errs, err)
+			problem = &arrange.Problem{}
+		}
+	}
+
+	if itemsFile != "" {
+		load(itemsFile, func(r io.Reader, name string) (err error) {
+			problem.Items, err = csvOptions().ReadItems(r, name)
+			return err
+		}, func(wb *arrange.Workbook, sheet string) (err error) {
+			problem.Items, err = wb.ReadItems(sheet)
+			return err
+		})
+	}
+	if rulesFile != "" {
+		load(rulesFile, func(r io.Reader, name string) (err error) {
+			problem.Rules, err = csvOptions().ReadRules(r, name)
+			return err
+		}, func(wb *arrange.Workbook, sheet string) (err error) {
+			problem.Rules, err = wb.ReadRules(sheet)
+			return err
+		})
+	}
+	if groupsFile != "" {
+		load(groupsFile, func(r io.Reader, name string) (err error) {
+			problem.Groups, err = csvOptions().ReadGroups(r, name)
+			return err
+		}, func(wb *arrange.Workbook, sheet string) (err error) {
+			problem.Groups, err = wb.ReadGroups(sheet)
+			return err
+		})
+	} else if maxNumGroups != 0 {
+		problem.Groups = nil
+		for i := 0; i < maxNumGroups; i++ {
+			problem.Groups = append(problem.Groups, &arrange.Group{Name: fmt.Sprintf("Group %d", i+1), MaxSize: maxGroupSize, MinSize: minGroupSize})
+		}
+	}
+	if historyFile != "" {
+		load(historyFile, func(r io.Reader, name string) (err error) {
+			problem.Options.PairHistory, err = csvOptions().ReadPairHistory(r, name)
+			return err
+		}, func(wb *arrange.Workbook, sheet string) (err error) {
+			problem.Options.PairHistory, err = wb.ReadPairHistory(sheet)
+			return err
+		})
+	}
+
+	if startFile != "" {
+		load(startFile, func(r io.Reader, name string) (err error) {
+			problem.Options.Start, err = csvOptions().ReadArrangement(r, name)
+			return err
+		}, func(wb *arrange.Workbook, sheet string) (err error) {
+			problem.Options.Start, err = wb.ReadArrangement(sheet)
+			return err
+		})
+	}
+
+	if len(errs) > 0 {
+		fmt.Println("problems with the input:")
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+	normalizeItems(problem.Items, problem.Rules)
+	if showProgress {
+		problem.Options.Progress = printProgress
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "timeout-secs":
+			problem.Options.Timeout = time.Second * time.Duration(timeoutSeconds)
+		case "solver":
+			problem.Options.Solver = arrange.Solver(solver)
+		case "tabu-tenure":
+			problem.Options.TabuTenure = tabuTenure
+		case "tabu-patience":
+			problem.Options.TabuPatience = tabuPatience
+		case "seeding":
+			problem.Options.Seedings = nil
+			for _, seeding := range strings.Split(seedings, ",") {
+				if seeding = strings.TrimSpace(seeding); seeding != "" {
+					problem.Options.Seedings = append(problem.Options.Seedings, arrange.Seeding(seeding))
+				}
+			}
+		case "stop-after-restarts":
+			problem.Options.StopAfterRestarts = stopAfterRestarts
+		case "stop-after-secs":
+			problem.Options.StopAfterNoImprovement = time.Second * time.Duration(stopAfterSeconds)
+		case "target-score":
+			score, err := arrange.ParseScore(targetScore)
+			if err != nil {
+				fmt.Printf("bad -target-score: %v\n", err)
+				os.Exit(1)
+			}
+			problem.Options.TargetScore = score
+		case "max-states":
+			problem.Options.MaxStates = maxStates
+		case "max-visited-states":
+			problem.Options.MaxVisitedStates = maxVisitedStates
+		case "bundle-tag":
+			problem.Options.BundleTag = bundleTag
+		case "repeat-weight":
+			problem.Options.RepeatWeight = repeatWeight
+		case "repeat-priority":
+			problem.Options.RepeatPriority = repeatPriority
+		}
+	})
+	if problemFile == "" {
+		problem.Options.RepeatWeight = repeatWeight
+	}
+	return problem
+}
@@ -1,5 +1,24 @@
 package main
 
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestParseDelimiter(t *testing.T) {
+	for str, exp := range map[string]rune{"": 0, ";": ';', "tab": '\t', `\t`: '\t', "Comma": ',', "semicolon": ';', "pipe": '|', "¦": '¦'} {
+		r, err := parseDelimiter(str)
+		assert.Equal(t, nil, err, str)
+		assert.Equal(t, exp, r, str)
+	}
+
+	_, err := parseDelimiter("semi")
+	assert.Equal(t, `"semi" should be a single character, or tab, comma, semicolon or pipe`, err.Error())
+	_, err = parseDelimiter(`"`)
+	assert.Equal(t, `"\"" can't be used as a delimiter`, err.Error())
+}
+
 //type Test struct {
 //	Args        []string
 //	Input       string
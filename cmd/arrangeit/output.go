@@ -0,0 +1,557 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dankinder/arrangeit/arrange"
+	"github.com/dankinder/arrangeit/internal/xlsx"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+// The values accepted by -output-format
+const (
+	formatTable    = "table"
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+
+	formatRoster     = "roster"
+	formatRosterHTML = "roster-html"
+
+	formatXLSX = "xlsx"
+)
+
+var outputFormats = []string{formatTable, formatJSON, formatCSV, formatMarkdown, formatHTML, formatRoster, formatRosterHTML,
+	formatXLSX}
+
+// formatForFile returns the output format that the extension of path is usual for, or "" if there isn't one.
+func formatForFile(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON
+	case ".csv":
+		return formatCSV
+	case ".md", ".markdown":
+		return formatMarkdown
+	case ".html", ".htm":
+		return formatHTML
+	case ".xlsx":
+		return formatXLSX
+	}
+	return ""
+}
+
+// outputOptions are the settings from the command line that affect the output.
+type outputOptions struct {
+	Format string
+
+	// The tags to include, or nil for the tags the rules use (for tables and rosters) or every tag (for JSON and CSV)
+	Columns []string
+
+	// Path to a template to use instead of the default for rosters, or ""
+	RosterTemplate string
+}
+
+// writeResults writes the arrangement from each round (just one unless using -rounds). history is the pairings from
+// all rounds, or nil if not using -rounds.
+func writeResults(w io.Writer, opts outputOptions, problem *arrange.Problem, results []*arrange.Result, history *arrange.PairHistory) error {
+	format, columns := opts.Format, opts.Columns
+	switch format {
+	case formatTable, formatMarkdown, formatHTML:
+		for i, result := range results {
+			if history != nil {
+				writeHeading(w, format, fmt.Sprintf("Round %d", i+1))
+			}
+			tagNames := columns
+			if tagNames == nil {
+				tagNames = ruleTagNames(problem.Rules)
+			}
+			fmt.Fprintln(w, renderTable(arrangementTable(result.Groups, tagNames, format), format))
+			writePreferenceCounts(w, format, result)
+			if history != nil {
+				fmt.Fprintln(w)
+			}
+		}
+		if history != nil {
+			writeHeading(w, format, "Times each pair has been together")
+			writePairRepeats(w, format, problem.Items, history)
+		}
+		return nil
+
+	case formatJSON:
+		var arrangements []jsonArrangement
+		for i, result := range results {
+			arrangement := newJSONArrangement(result, columns)
+			if history != nil {
+				arrangement.Round = i + 1
+			}
+			arrangements = append(arrangements, arrangement)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if history == nil {
+			return encoder.Encode(arrangements[0])
+		}
+		return encoder.Encode(jsonRotation{Rounds: arrangements, PairCounts: newJSONPairCounts(problem.Items, history)})
+
+	case formatCSV:
+		return writeCSV(w, results, history != nil, columns)
+
+	case formatRoster, formatRosterHTML:
+		return writeRosters(w, opts, problem.Rules, results, history != nil)
+
+	case formatXLSX:
+		return writeXLSX(w, opts, problem.Rules, results, history != nil)
+
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func writeHeading(w io.Writer, format, heading string) {
+	switch format {
+	case formatMarkdown:
+		fmt.Fprintf(w, "## %s\n\n", heading)
+	case formatHTML:
+		fmt.Fprintf(w, "<h2>%s</h2>\n", heading)
+	default:
+		fmt.Fprintln(w, heading)
+	}
+}
+
+func renderTable(tw table.Writer, format string) string {
+	switch format {
+	case formatMarkdown:
+		return tw.RenderMarkdown()
+	case formatHTML:
+		return tw.RenderHTML()
+	default:
+		return tw.Render()
+	}
+}
+
+// markdownEscaper escapes the text in Markdown tables that would otherwise be taken as HTML. go-pretty already escapes
+// "|" in Markdown, and everything in HTML.
+var markdownEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// cellText returns text to put in a table that's rendered in the format.
+func cellText(format, text string) string {
+	if format == formatMarkdown {
+		return markdownEscaper.Replace(text)
+	}
+	return text
+}
+
+// arrangementTable makes a table of the items in each group, with the given tags, to be rendered in the format.
+func arrangementTable(arrangement []*arrange.Group, tagNames []string, format string) table.Writer {
+	tw := table.NewWriter()
+
+	header := table.Row{"Group", "Item"}
+	for _, tagName := range tagNames {
+		header = append(header, cellText(format, tagName))
+	}
+	tw.AppendHeader(header)
+
+	for i, group := range arrangement {
+		if i > 0 {
+			tw.AppendRow(table.Row{""})
+		}
+		for _, item := range group.Items {
+			row := table.Row{cellText(format, group.Name), cellText(format, item.ID)}
+			for _, tagName := range tagNames {
+				row = append(row, cellText(format, item.Tags[tagName]))
+			}
+			tw.AppendRow(row)
+		}
+	}
+	return tw
+}
+
+// ruleTagNames returns the names of the tags used by the rules, in order and without duplicates.
+func ruleTagNames(rules []*arrange.Rule) []string {
+	var tagNames []string
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !seen[rule.TagName] {
+			seen[rule.TagName] = true
+			tagNames = append(tagNames, rule.TagName)
+		}
+	}
+	return tagNames
+}
+
+// preferenceRules returns the GroupPreference rules that were scored for the result.
+func preferenceRules(result *arrange.Result) []*arrange.Rule {
+	var rules []*arrange.Rule
+	for _, rs := range result.Breakdown {
+		if rs.Rule != nil && rs.Rule.Type == arrange.RuleTypeGroupPreference {
+			rules = append(rules, rs.Rule)
+		}
+	}
+	return rules
+}
+
+// writePreferenceCounts writes how many items got each of their choices of group, for each GroupPreference rule.
+func writePreferenceCounts(w io.Writer, format string, result *arrange.Result) {
+	for _, rule := range preferenceRules(result) {
+		counts := arrange.CountPreferences(rule, result.Groups)
+		if format == formatTable {
+			for rank, count := range counts.ByRank {
+				fmt.Fprintf(w, "Got their %s choice of %s: %d\n", ordinal(rank+1), rule.TagName, count)
+			}
+			fmt.Fprintf(w, "Got none of their choices of %s: %d\n", rule.TagName, counts.Unranked)
+			continue
+		}
+		summary := table.NewWriter()
+		summary.AppendHeader(table.Row{cellText(format, "Choice of "+rule.TagName), "Items"})
+		for rank, count := range counts.ByRank {
+			summary.AppendRow(table.Row{ordinal(rank + 1), count})
+		}
+		summary.AppendRow(table.Row{"None", counts.Unranked})
+		// Keep it apart from the arrangement's table
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, renderTable(summary, format))
+	}
+}
+
+// ordinal returns n written like 1st, 2nd or 3rd.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// writePairRepeats writes a matrix of how many times each pair of items has been together (including any loaded
+// history), followed by a summary of how often pairs were repeated.
+func writePairRepeats(w io.Writer, format string, items []*arrange.Item, history *arrange.PairHistory) {
+	tw := table.NewWriter()
+
+	header := table.Row{""}
+	for _, item := range items {
+		header = append(header, cellText(format, item.ID))
+	}
+	tw.AppendHeader(header)
+
+	// Maps a number of times together to how many pairs were together that many times
+	pairsByCount := map[int]int{}
+	var maxCount int
+	for i, item1 := range items {
+		row := table.Row{cellText(format, item1.ID)}
+		for j, item2 := range items {
+			if i == j {
+				row = append(row, "-")
+				continue
+			}
+			count := history.Count(item1.ID, item2.ID)
+			if count == 0 {
+				row = append(row, "")
+			} else {
+				row = append(row, count)
+			}
+			if j > i {
+				pairsByCount[count]++
+				if count > maxCount {
+					maxCount = count
+				}
+			}
+		}
+		tw.AppendRow(row)
+	}
+	fmt.Fprintln(w, renderTable(tw, format))
+
+	if format == formatTable {
+		for count := 0; count <= maxCount; count++ {
+			fmt.Fprintf(w, "Pairs together %d times: %d\n", count, pairsByCount[count])
+		}
+		return
+	}
+	summary := table.NewWriter()
+	summary.AppendHeader(table.Row{"Times together", "Pairs"})
+	for count := 0; count <= maxCount; count++ {
+		summary.AppendRow(table.Row{count, pairsByCount[count]})
+	}
+	fmt.Fprintln(w, renderTable(summary, format))
+}
+
+// The structure of -output-format json
+type jsonArrangement struct {
+	// Only set when using -rounds
+	Round int `json:"round,omitempty"`
+
+	Score          arrange.Score   `json:"score"`
+	ScoreBreakdown []jsonRuleScore `json:"scoreBreakdown"`
+	Groups         []jsonGroup     `json:"groups"`
+
+	// How many items got each of their choices, for each GroupPreference rule
+	Preferences []jsonPreferenceCounts `json:"preferences,omitempty"`
+
+	// Why the search ended, if this was the result of one
+	StopReason arrange.StopReason `json:"stopReason,omitempty"`
+}
+
+type jsonRuleScore struct {
+	Name     string  `json:"name"`
+	TagName  string  `json:"tagName,omitempty"`
+	Type     string  `json:"type,omitempty"`
+	Priority int     `json:"priority"`
+	Tier     int     `json:"tier"`
+	Score    float64 `json:"score"`
+}
+
+type jsonPreferenceCounts struct {
+	TagName string `json:"tagName"`
+
+	// How many items got their first choice, their second, and so on
+	ByRank []int `json:"byRank"`
+
+	// How many items got none of their choices
+	Unranked int `json:"unranked"`
+}
+
+type jsonGroup struct {
+	Name    string     `json:"name"`
+	MinSize int        `json:"minSize"`
+	MaxSize int        `json:"maxSize"`
+	Items   []jsonItem `json:"items"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+type jsonItem struct {
+	ID   string            `json:"id"`
+	Tags map[string]string `json:"tags"`
+}
+
+type jsonRotation struct {
+	Rounds     []jsonArrangement `json:"rounds"`
+	PairCounts []jsonPairCount   `json:"pairCounts"`
+}
+
+type jsonPairCount struct {
+	Items [2]string `json:"items"`
+	Count int       `json:"count"`
+}
+
+// newJSONArrangement converts a result to JSON, with only the given tags of each item, or all of them if nil.
+func newJSONArrangement(result *arrange.Result, tagNames []string) jsonArrangement {
+	arrangement := jsonArrangement{
+		Score:          result.Score,
+		ScoreBreakdown: []jsonRuleScore{},
+		Groups:         []jsonGroup{},
+		StopReason:     result.StopReason,
+	}
+	for _, rs := range result.Breakdown {
+		jrs := jsonRuleScore{Name: rs.Name, Tier: rs.Tier, Score: rs.Score}
+		if rs.Rule != nil {
+			jrs.TagName = rs.Rule.TagName
+			jrs.Type = string(rs.Rule.Type)
+			jrs.Priority = rs.Rule.Priority
+		}
+		arrangement.ScoreBreakdown = append(arrangement.ScoreBreakdown, jrs)
+	}
+	for _, rule := range preferenceRules(result) {
+		counts := arrange.CountPreferences(rule, result.Groups)
+		arrangement.Preferences = append(arrangement.Preferences, jsonPreferenceCounts{
+			TagName:  rule.TagName,
+			ByRank:   append([]int{}, counts.ByRank...),
+			Unranked: counts.Unranked,
+		})
+	}
+	for _, group := range result.Groups {
+		jg := jsonGroup{Name: group.Name, MinSize: group.MinSize, MaxSize: group.MaxSize, Items: []jsonItem{}, Tags: group.Tags}
+		for _, item := range group.Items {
+			tags := item.Tags
+			if tagNames != nil {
+				tags = map[string]string{}
+				for _, tagName := range tagNames {
+					tags[tagName] = item.Tags[tagName]
+				}
+			}
+			jg.Items = append(jg.Items, jsonItem{ID: item.ID, Tags: tags})
+		}
+		arrangement.Groups = append(arrangement.Groups, jg)
+	}
+	return arrangement
+}
+
+// newJSONPairCounts lists every pair of items that has been together at least once.
+func newJSONPairCounts(items []*arrange.Item, history *arrange.PairHistory) []jsonPairCount {
+	pairCounts := []jsonPairCount{}
+	for i, item1 := range items {
+		for _, item2 := range items[i+1:] {
+			if count := history.Count(item1.ID, item2.ID); count > 0 {
+				pairCounts = append(pairCounts, jsonPairCount{[2]string{item1.ID, item2.ID}, count})
+			}
+		}
+	}
+	return pairCounts
+}
+
+// writeCSV writes one row per item with its group and the given tags (or every tag if nil), which can be read back in
+// with arrange.ReadArrangementCSV (e.g. with -score). With multiple rounds, the round number is the first column.
+func writeCSV(w io.Writer, results []*arrange.Result, withRound bool, tagNames []string) error {
+	if tagNames == nil {
+		tagNames = allTagNames(results)
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"Group", "Item"}
+	if withRound {
+		header = append([]string{"Round"}, header...)
+	}
+	if err := cw.Write(append(header, tagNames...)); err != nil {
+		return err
+	}
+	for i, result := range results {
+		for _, group := range result.Groups {
+			for _, item := range group.Items {
+				row := []string{group.Name, item.ID}
+				if withRound {
+					row = append([]string{fmt.Sprint(i + 1)}, row...)
+				}
+				for _, tagName := range tagNames {
+					row = append(row, item.Tags[tagName])
+				}
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeXLSX writes a workbook with a Summary sheet, an Arrangement sheet laid out like the CSV format, which can be read
+// back in with -score, and a sheet for each group (in each round).
+func writeXLSX(w io.Writer, opts outputOptions, rules []*arrange.Rule, results []*arrange.Result, withRound bool) error {
+	xw := xlsx.NewWriter(w)
+
+	columns := opts.Columns
+	if columns == nil {
+		columns = ruleTagNames(rules)
+	}
+	roundPrefix := func(i int) []interface{} {
+		if withRound {
+			return []interface{}{i + 1}
+		}
+		return nil
+	}
+	var header []interface{}
+	if withRound {
+		header = []interface{}{"Round"}
+	}
+
+	summary := [][]interface{}{append(header, "Group", "Members", "MinSize", "MaxSize")}
+	for i, result := range results {
+		for _, group := range result.Groups {
+			summary = append(summary, append(roundPrefix(i), group.Name, len(group.Items), group.MinSize, group.MaxSize))
+		}
+	}
+	summary = append(summary, nil, append(header, "Score", "Tier", "Points"))
+	for i, result := range results {
+		summary = append(summary, append(roundPrefix(i), "Total", "", cellValue(result.Score.String())))
+		for _, rs := range result.Breakdown {
+			summary = append(summary, append(roundPrefix(i), rs.Name, rs.Tier+1, rs.Score))
+		}
+	}
+	if err := xw.AddSheet("Summary", summary); err != nil {
+		return err
+	}
+
+	tagNames := opts.Columns
+	if tagNames == nil {
+		tagNames = allTagNames(results)
+	}
+	arrangement := [][]interface{}{append(header, "Group", "Item")}
+	for _, tagName := range tagNames {
+		arrangement[0] = append(arrangement[0], tagName)
+	}
+	for i, result := range results {
+		for _, group := range result.Groups {
+			for _, item := range group.Items {
+				row := append(roundPrefix(i), group.Name, cellValue(item.ID))
+				for _, tagName := range tagNames {
+					row = append(row, cellValue(item.Tags[tagName]))
+				}
+				arrangement = append(arrangement, row)
+			}
+		}
+	}
+	if err := xw.AddSheet("Arrangement", arrangement); err != nil {
+		return err
+	}
+
+	// The group sheets come last, so that a group named e.g. Arrangement gets a different name rather than the sheet
+	// that -score reads
+	for i, result := range results {
+		for _, group := range result.Groups {
+			rows := [][]interface{}{{"Item"}}
+			for _, column := range columns {
+				rows[0] = append(rows[0], column)
+			}
+			for _, item := range group.Items {
+				row := []interface{}{cellValue(item.ID)}
+				for _, column := range columns {
+					row = append(row, cellValue(item.Tags[column]))
+				}
+				rows = append(rows, row)
+			}
+			name := group.Name
+			if withRound {
+				name = fmt.Sprintf("Round %d %s", i+1, group.Name)
+			}
+			if err := xw.AddSheet(name, rows); err != nil {
+				return err
+			}
+		}
+	}
+	return xw.Close()
+}
+
+// cellValue returns a number if the value is written the way it would be shown in a spreadsheet, so that it isn't
+// marked as a number stored as text, and otherwise the value as it is. Values like "007" stay text.
+func cellValue(value string) interface{} {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || strconv.FormatFloat(f, 'f', -1, 64) != value {
+		return value
+	}
+	return f
+}
+
+// allTagNames returns the name of every tag of every item in the results, sorted.
+func allTagNames(results []*arrange.Result) []string {
+	seen := map[string]bool{}
+	var tagNames []string
+	for _, result := range results {
+		for _, group := range result.Groups {
+			for _, item := range group.Items {
+				for tagName := range item.Tags {
+					if !seen[tagName] {
+						seen[tagName] = true
+						tagNames = append(tagNames, tagName)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(tagNames)
+	return tagNames
+}
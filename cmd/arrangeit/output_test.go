@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/dankinder/arrangeit/arrange"
+)
+
+// testOutput returns an arrangement with tag values that need escaping in some formats.
+func testOutput() (*arrange.Problem, []*arrange.Result) {
+	bob := &arrange.Item{ID: "bob", Tags: map[string]string{"gender": "m", "note": "a|b"}}
+	sue := &arrange.Item{ID: "sue", Tags: map[string]string{"gender": "f", "note": "<b>hi</b>"}}
+	joe := &arrange.Item{ID: "joe", Tags: map[string]string{"gender": "m", "note": ""}}
+	rule := &arrange.Rule{TagName: "gender", Type: arrange.RuleTypeSameness, Weight: 1}
+	problem := &arrange.Problem{
+		Items:  []*arrange.Item{bob, sue, joe},
+		Rules:  []*arrange.Rule{rule},
+		Groups: []*arrange.Group{&arrange.Group{Name: "Van", MaxSize: 2}, &arrange.Group{Name: "Car", MaxSize: 2}},
+	}
+	result := &arrange.Result{
+		Groups: []*arrange.Group{
+			&arrange.Group{Name: "Van", MaxSize: 2, Items: []*arrange.Item{bob, joe}},
+			&arrange.Group{Name: "Car", MaxSize: 2, Items: []*arrange.Item{sue}},
+		},
+		Score:      arrange.Score{5},
+		Breakdown:  []arrange.RuleScore{{Rule: rule, Name: "Sameness on gender", Score: 5}},
+		StopReason: arrange.StopReasonExhausted,
+	}
+	return problem, []*arrange.Result{result}
+}
+
+func TestWriteResults(t *testing.T) {
+	problem, results := testOutput()
+	for _, tc := range []struct {
+		format  string
+		columns []string
+		exp     []string
+		notExp  []string
+	}{
+		{formatTable, nil, []string{"| GROUP | ITEM | GENDER |", "| Van   | bob  | m      |", "| Car   | sue  | f      |"},
+			[]string{"note"}},
+		{formatCSV, nil, []string{"Group,Item,gender,note\n", "Van,bob,m,a|b\n", "Van,joe,m,\n", "Car,sue,f,<b>hi</b>\n"}, nil},
+		{formatCSV, []string{"note"}, []string{"Group,Item,note\n", "Van,bob,a|b\n"}, []string{"gender"}},
+		{formatMarkdown, []string{"note"}, []string{"| Group | Item | note |\n| --- | --- | --- |\n", `| Van | bob | a\|b |`,
+			"| Car | sue | &lt;b&gt;hi&lt;/b&gt; |"}, []string{"<b>"}},
+		{formatHTML, []string{"note"}, []string{"<th>note</th>", "<td>a|b</td>", "<td>&lt;b&gt;hi&lt;/b&gt;</td>"},
+			[]string{"<b>"}},
+	} {
+		var buf bytes.Buffer
+		assert.Equal(t, nil, writeResults(&buf, outputOptions{Format: tc.format, Columns: tc.columns}, problem, results, nil))
+		for _, exp := range tc.exp {
+			assert.T(t, strings.Contains(buf.String(), exp), tc.format, exp, buf.String())
+		}
+		for _, notExp := range tc.notExp {
+			assert.T(t, !strings.Contains(buf.String(), notExp), tc.format, notExp, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, `unknown output format "yaml"`, writeResults(&buf, outputOptions{Format: "yaml"}, problem, results, nil).Error())
+}
+
+func TestWriteResultsJSON(t *testing.T) {
+	problem, results := testOutput()
+	var buf bytes.Buffer
+	assert.Equal(t, nil, writeResults(&buf, outputOptions{Format: formatJSON, Columns: []string{"note"}}, problem, results, nil))
+
+	var arrangement jsonArrangement
+	assert.Equal(t, nil, json.Unmarshal(buf.Bytes(), &arrangement))
+	assert.Equal(t, jsonArrangement{
+		Score: arrange.Score{5},
+		ScoreBreakdown: []jsonRuleScore{
+			{Name: "Sameness on gender", TagName: "gender", Type: "Sameness", Score: 5},
+		},
+		Groups: []jsonGroup{
+			{Name: "Van", MaxSize: 2, Items: []jsonItem{
+				{ID: "bob", Tags: map[string]string{"note": "a|b"}},
+				{ID: "joe", Tags: map[string]string{"note": ""}},
+			}},
+			{Name: "Car", MaxSize: 2, Items: []jsonItem{
+				{ID: "sue", Tags: map[string]string{"note": "<b>hi</b>"}},
+			}},
+		},
+		StopReason: arrange.StopReasonExhausted,
+	}, arrangement)
+}
+
+func TestWriteResultsCSVRoundTrip(t *testing.T) {
+	problem, results := testOutput()
+	var buf bytes.Buffer
+	assert.Equal(t, nil, writeResults(&buf, outputOptions{Format: formatCSV}, problem, results, nil))
+
+	groups, err := arrange.ReadArrangementCSV(&buf, "arrangement.csv")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(groups))
+	for i, group := range groups {
+		assert.Equal(t, results[0].Groups[i].Name, group.Name)
+		assert.Equal(t, len(results[0].Groups[i].Items), len(group.Items))
+		for j, item := range group.Items {
+			assert.Equal(t, results[0].Groups[i].Items[j], item)
+		}
+	}
+
+	// The arrangement read back can be used to start a search from again, as with -start
+	var first *arrange.Result
+	_, err = arrange.Arrange(context.Background(), problem.Items, problem.Rules, problem.Groups, arrange.Options{
+		Start:     groups,
+		MaxStates: 1,
+		Progress: func(p arrange.Progress) {
+			if first == nil {
+				first = p.Best
+			}
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, results[0].Score, first.Score)
+	for i, group := range first.Groups {
+		assert.Equal(t, results[0].Groups[i].Name, group.Name)
+		assert.Equal(t, results[0].Groups[i].Items, group.Items)
+	}
+}
+
+func TestFormatForFile(t *testing.T) {
+	for path, exp := range map[string]string{
+		"groups.json":      formatJSON,
+		"out/groups.csv":   formatCSV,
+		"groups.md":        formatMarkdown,
+		"groups.markdown":  formatMarkdown,
+		"GROUPS.HTML":      formatHTML,
+		"groups.htm":       formatHTML,
+		"groups.xlsx":      formatXLSX,
+		"groups.txt":       "",
+		"groups":           "",
+		"":                 "",
+		"groups.json/list": "",
+	} {
+		assert.Equal(t, exp, formatForFile(path), path)
+	}
+}
+
+func TestWriteResultsXLSX(t *testing.T) {
+	problem, results := testOutput()
+	// A group named like one of the other sheets doesn't take its place
+	results[0].Groups[1].Name = "Arrangement"
+	var buf bytes.Buffer
+	assert.Equal(t, nil, writeResults(&buf, outputOptions{Format: formatXLSX}, problem, results, nil))
+
+	wb, err := arrange.ReadWorkbook(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "groups.xlsx")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"Summary", "Arrangement", "Van", "Arrangement (2)"}, wb.SheetNames())
+	groups, err := wb.ReadArrangement("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, "Arrangement", groups[1].Name)
+	assert.Equal(t, "sue", groups[1].Items[0].ID)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dankinder/arrangeit/arrange"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+// rosterTemplate is the template used by -output-format roster, with one page per group separated by form feeds.
+const rosterTemplate = `{{range $i, $group := .Groups}}{{if $i}}{{"\f"}}{{end -}}
+{{if $group.Round}}Round {{$group.Round}}: {{end}}{{$group.Name}}
+{{$group.Size}} members (group {{$group.Number}} of {{$group.Of}})
+
+{{$group.Table}}
+{{range $group.Counts}}
+{{.TagName}}:{{range .Values}} {{.Value}} {{.Count}};{{end}}{{end}}
+{{- range $group.Averages}}
+{{.TagName}}: average {{printf "%.1f" .Average}}{{end}}
+{{end}}`
+
+// rosterHTMLTemplate is the template used by -output-format roster-html, with one printed page per group.
+const rosterHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rosters</title>
+<style>
+body { font-family: sans-serif; }
+section { page-break-after: always; }
+section:last-of-type { page-break-after: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.2em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+{{range .Groups}}<section>
+<h1>{{if .Round}}Round {{.Round}}: {{end}}{{.Name}}</h1>
+<p>{{.Size}} members (group {{.Number}} of {{.Of}})</p>
+<table>
+<thead><tr><th>Item</th>{{range $.Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Items}}<tr><td>{{.ID}}</td>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{if or .Counts .Averages}}<h2>Summary</h2>
+<ul>
+{{range .Counts}}<li>{{.TagName}}:{{range .Values}} {{.Value}} {{.Count}};{{end}}</li>
+{{end}}{{range .Averages}}<li>{{.TagName}}: average {{printf "%.1f" .Average}}</li>
+{{end}}</ul>
+{{end}}</section>
+{{end}}</body>
+</html>
+`
+
+// rosterData is what roster templates are executed with.
+type rosterData struct {
+	// The tags shown for each item
+	Columns []string
+
+	// Every group, from every round when using -rounds
+	Groups []rosterGroup
+}
+
+type rosterGroup struct {
+	Name string
+
+	// The round number when using -rounds, otherwise 0
+	Round int
+
+	// Position of the group in its round's arrangement, starting at 1, out of how many groups there are
+	Number, Of int
+
+	Size  int
+	Items []rosterItem
+
+	// Summary statistics for each of the columns: averages for tags whose values are all numbers, otherwise the number
+	// of items with each value
+	Counts   []tagCounts
+	Averages []tagAverage
+
+	// The items and columns as a plain-text table
+	Table string
+}
+
+type rosterItem struct {
+	ID   string
+	Tags map[string]string
+
+	// The item's value for each of rosterData.Columns
+	Values []string
+}
+
+type tagCounts struct {
+	TagName string
+	Values  []valueCount
+}
+
+type valueCount struct {
+	Value string
+	Count int
+}
+
+type tagAverage struct {
+	TagName string
+	Average float64
+}
+
+// executer is satisfied by both text/template and html/template templates.
+type executer interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// parseRosterTemplate returns the template for the roster format, from templatePath if given. HTML templates use
+// html/template, so that tag values are escaped.
+func parseRosterTemplate(format, templatePath string) (executer, error) {
+	text := rosterTemplate
+	if format == formatRosterHTML {
+		text = rosterHTMLTemplate
+	}
+	if templatePath != "" {
+		b, err := ioutil.ReadFile(templatePath)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+
+	if format == formatRosterHTML {
+		return htmltemplate.New("roster").Parse(text)
+	}
+	return template.New("roster").Parse(text)
+}
+
+// writeRosters writes each round's arrangement with the template, one section per group.
+func writeRosters(w io.Writer, opts outputOptions, rules []*arrange.Rule, results []*arrange.Result, withRound bool) error {
+	tmpl, err := parseRosterTemplate(opts.Format, opts.RosterTemplate)
+	if err != nil {
+		return err
+	}
+
+	columns := opts.Columns
+	if columns == nil {
+		columns = ruleTagNames(rules)
+	}
+	data := &rosterData{Columns: columns}
+	for i, result := range results {
+		round := 0
+		if withRound {
+			round = i + 1
+		}
+		data.Groups = append(data.Groups, newRosterGroups(result.Groups, columns, round)...)
+	}
+	return tmpl.Execute(w, data)
+}
+
+func newRosterGroups(groups []*arrange.Group, columns []string, round int) []rosterGroup {
+	var rosterGroups []rosterGroup
+	for i, group := range groups {
+		rg := rosterGroup{Name: group.Name, Round: round, Number: i + 1, Of: len(groups), Size: len(group.Items)}
+
+		tw := table.NewWriter()
+		header := table.Row{"Item"}
+		for _, column := range columns {
+			header = append(header, column)
+		}
+		tw.AppendHeader(header)
+
+		for _, item := range group.Items {
+			ri := rosterItem{ID: item.ID, Tags: item.Tags}
+			row := table.Row{item.ID}
+			for _, column := range columns {
+				ri.Values = append(ri.Values, item.Tags[column])
+				row = append(row, item.Tags[column])
+			}
+			rg.Items = append(rg.Items, ri)
+			tw.AppendRow(row)
+		}
+		rg.Table = tw.Render()
+
+		for _, column := range columns {
+			if average, ok := numericAverage(group.Items, column); ok {
+				rg.Averages = append(rg.Averages, tagAverage{column, average})
+			} else {
+				rg.Counts = append(rg.Counts, countValues(group.Items, column))
+			}
+		}
+		rosterGroups = append(rosterGroups, rg)
+	}
+	return rosterGroups
+}
+
+// numericAverage returns the average of the tag's values if they're all numbers, ignoring items without the tag.
+func numericAverage(items []*arrange.Item, tagName string) (float64, bool) {
+	var sum float64
+	var n int
+	for _, item := range items {
+		value := strings.TrimSpace(item.Tags[tagName])
+		if value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+		sum += f
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// countValues counts the items with each value of the tag, most common first.
+func countValues(items []*arrange.Item, tagName string) tagCounts {
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[item.Tags[tagName]]++
+	}
+	tc := tagCounts{TagName: tagName}
+	for value, count := range counts {
+		if value == "" {
+			value = "(none)"
+		}
+		tc.Values = append(tc.Values, valueCount{value, count})
+	}
+	sort.Slice(tc.Values, func(i, j int) bool {
+		if tc.Values[i].Count != tc.Values[j].Count {
+			return tc.Values[i].Count > tc.Values[j].Count
+		}
+		return tc.Values[i].Value < tc.Values[j].Value
+	})
+	return tc
+}
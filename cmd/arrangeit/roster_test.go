@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/dankinder/arrangeit/arrange"
+)
+
+var rosterColumns = []string{"gender", "age", "note"}
+
+func testRosterResults() []*arrange.Result {
+	return []*arrange.Result{&arrange.Result{Groups: []*arrange.Group{
+		&arrange.Group{Name: "Van", Items: []*arrange.Item{
+			&arrange.Item{ID: "bob", Tags: map[string]string{"gender": "m", "age": "15", "note": "<b>loud</b>"}},
+			&arrange.Item{ID: "joe", Tags: map[string]string{"gender": "m", "age": "16", "note": "Tom & Jerry"}},
+			&arrange.Item{ID: "sue", Tags: map[string]string{"gender": "f", "age": "", "note": ""}},
+		}},
+		&arrange.Group{Name: "Car", Items: []*arrange.Item{
+			&arrange.Item{ID: "ann", Tags: map[string]string{"gender": "f", "age": "14"}},
+		}},
+	}}}
+}
+
+// writeTemplate writes a roster template to a temporary directory, which the caller must remove.
+func writeTemplate(t *testing.T, text string) (dir, path string) {
+	dir, err := ioutil.TempDir("", "roster")
+	assert.Equal(t, nil, err)
+	path = filepath.Join(dir, "roster.tmpl")
+	assert.Equal(t, nil, ioutil.WriteFile(path, []byte(text), 0644))
+	return dir, path
+}
+
+func TestNumericAverage(t *testing.T) {
+	items := testRosterResults()[0].Groups[0].Items
+	average, ok := numericAverage(items, "age")
+	assert.T(t, ok)
+	assert.Equal(t, 15.5, average)
+
+	_, ok = numericAverage(items, "gender")
+	assert.T(t, !ok)
+	_, ok = numericAverage(items, "missing")
+	assert.T(t, !ok)
+}
+
+func TestCountValues(t *testing.T) {
+	items := testRosterResults()[0].Groups[0].Items
+	assert.Equal(t, tagCounts{TagName: "gender", Values: []valueCount{{"m", 2}, {"f", 1}}}, countValues(items, "gender"))
+	assert.Equal(t, tagCounts{TagName: "age", Values: []valueCount{{"(none)", 1}, {"15", 1}, {"16", 1}}},
+		countValues(items, "age"))
+}
+
+func TestWriteRosters(t *testing.T) {
+	var buf bytes.Buffer
+	opts := outputOptions{Format: formatRoster, Columns: rosterColumns}
+	assert.Equal(t, nil, writeRosters(&buf, opts, nil, testRosterResults(), false))
+	assert.Equal(t, `Van
+3 members (group 1 of 2)
+
++------+--------+-----+-------------+
+| ITEM | GENDER | AGE | NOTE        |
++------+--------+-----+-------------+
+| bob  | m      | 15  | <b>loud</b> |
+| joe  | m      | 16  | Tom & Jerry |
+| sue  | f      |     |             |
++------+--------+-----+-------------+
+
+gender: m 2; f 1;
+note: (none) 1; <b>loud</b> 1; Tom & Jerry 1;
+age: average 15.5
+`+"\f"+`Car
+1 members (group 2 of 2)
+
++------+--------+-----+------+
+| ITEM | GENDER | AGE | NOTE |
++------+--------+-----+------+
+| ann  | f      | 14  |      |
++------+--------+-----+------+
+
+gender: f 1;
+note: (none) 1;
+age: average 14.0
+`, buf.String())
+
+	// With -rounds, each group says which round it's from
+	buf.Reset()
+	results := append(testRosterResults(), testRosterResults()...)
+	assert.Equal(t, nil, writeRosters(&buf, opts, nil, results, true))
+	assert.T(t, strings.HasPrefix(buf.String(), "Round 1: Van\n"))
+	assert.T(t, strings.Contains(buf.String(), "\fRound 2: Car\n"))
+}
+
+func TestWriteRostersHTML(t *testing.T) {
+	var buf bytes.Buffer
+	opts := outputOptions{Format: formatRosterHTML, Columns: rosterColumns}
+	assert.Equal(t, nil, writeRosters(&buf, opts, nil, testRosterResults(), false))
+	html := buf.String()
+	for _, exp := range []string{
+		"<h1>Van</h1>\n<p>3 members (group 1 of 2)</p>",
+		"<thead><tr><th>Item</th><th>gender</th><th>age</th><th>note</th></tr></thead>",
+		"<tr><td>bob</td><td>m</td><td>15</td><td>&lt;b&gt;loud&lt;/b&gt;</td></tr>",
+		"<tr><td>joe</td><td>m</td><td>16</td><td>Tom &amp; Jerry</td></tr>",
+		"<li>note: (none) 1; &lt;b&gt;loud&lt;/b&gt; 1; Tom &amp; Jerry 1;</li>",
+		"<li>age: average 15.5</li>",
+		"<h1>Car</h1>",
+		"<li>age: average 14.0</li>",
+	} {
+		assert.T(t, strings.Contains(html, exp), exp)
+	}
+	assert.T(t, !strings.Contains(html, "<b>"))
+}
+
+func TestWriteRostersCustomTemplate(t *testing.T) {
+	dir, path := writeTemplate(t, `{{range .Groups}}{{.Name}}:{{range .Items}} {{.ID}} ({{index .Tags "note"}}){{end}}
+{{end}}`)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	opts := outputOptions{Format: formatRoster, Columns: rosterColumns, RosterTemplate: path}
+	assert.Equal(t, nil, writeRosters(&buf, opts, nil, testRosterResults(), false))
+	assert.Equal(t, "Van: bob (<b>loud</b>) joe (Tom & Jerry) sue ()\nCar: ann ()\n", buf.String())
+
+	// HTML templates escape the tag values
+	buf.Reset()
+	opts.Format = formatRosterHTML
+	assert.Equal(t, nil, writeRosters(&buf, opts, nil, testRosterResults(), false))
+	assert.Equal(t, "Van: bob (&lt;b&gt;loud&lt;/b&gt;) joe (Tom &amp; Jerry) sue ()\nCar: ann ()\n", buf.String())
+}
+
+func TestWriteRostersBadTemplate(t *testing.T) {
+	dir, path := writeTemplate(t, `{{range .Groups}}{{.Name}}`)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	opts := outputOptions{Format: formatRoster, RosterTemplate: path}
+	err := writeRosters(&buf, opts, nil, testRosterResults(), false)
+	assert.Equal(t, "template: roster:1: unexpected EOF", err.Error())
+
+	opts.RosterTemplate = filepath.Join(dir, "missing.tmpl")
+	err = writeRosters(&buf, opts, nil, testRosterResults(), false)
+	assert.T(t, os.IsNotExist(err))
+}
@@ -0,0 +1,623 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dankinder/arrangeit/arrange"
+)
+
+// maxRequestBytes limits the size of request bodies, which hold problems and arrangements.
+const maxRequestBytes = 10 << 20
+
+// serveOptions are the settings of `arrangeit serve`.
+type serveOptions struct {
+	Addr string
+
+	// How many jobs may run at once. Others wait for a slot.
+	MaxJobs int
+
+	// How many jobs may wait for a slot. Jobs submitted while that many are waiting are refused.
+	MaxQueued int
+
+	// The timeout of jobs whose problem doesn't set one, and the most that a problem may set
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+
+	// How long finished jobs are kept for
+	JobTTL time.Duration
+}
+
+// runServe runs `arrangeit serve`, an HTTP server with a JSON API for arranging problems as background jobs:
+//
+//	POST   /jobs              submit a problem document (see -problem-schema); returns the job
+//	GET    /jobs/{id}         the job's status, and its arrangement once finished
+//	GET    /jobs/{id}/events  a stream of server-sent events with the job's status as it changes
+//	GET    /jobs/{id}/best    the best arrangement found so far
+//	DELETE /jobs/{id}         cancel the job, keeping the best arrangement found so far
+//	POST   /score             score an arrangement: {"problem": {...}, "arrangement": {"groups": [...]}}
+//	POST   /diff              compare two arrangements: {"a": {"groups": [...]}, "b": {"groups": [...]}}
+//
+// Arrangements are in the same JSON format as -output-format json; only group names and item IDs are needed.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var opts serveOptions
+	var defaultTimeoutSecs, maxTimeoutSecs int
+	fs.StringVar(&opts.Addr, "addr", ":8080", "address to listen on")
+	fs.IntVar(&opts.MaxJobs, "max-jobs", runtime.NumCPU(), "how many jobs may run at once; others wait for one to finish")
+	fs.IntVar(&opts.MaxQueued, "max-queued", 100, "how many jobs may wait to run; more are refused with 503 Service Unavailable")
+	fs.IntVar(&defaultTimeoutSecs, "default-timeout-secs", 30, "timeout of jobs whose problem doesn't set timeoutSecs")
+	fs.IntVar(&maxTimeoutSecs, "max-timeout-secs", 600, "most that a problem's timeoutSecs may be")
+	fs.DurationVar(&opts.JobTTL, "job-ttl", time.Hour, "how long finished jobs are kept for")
+	fs.Parse(args)
+	opts.DefaultTimeout = time.Duration(defaultTimeoutSecs) * time.Second
+	opts.MaxTimeout = time.Duration(maxTimeoutSecs) * time.Second
+
+	log.Printf("Listening on %s", opts.Addr)
+	log.Fatal(http.ListenAndServe(opts.Addr, newServer(opts)))
+}
+
+type server struct {
+	opts serveOptions
+	mux  *http.ServeMux
+
+	// Holds a value for each running job
+	slots chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newServer(opts serveOptions) *server {
+	if opts.MaxJobs < 1 {
+		opts.MaxJobs = 1
+	}
+	if opts.MaxQueued < 0 {
+		opts.MaxQueued = 0
+	}
+	s := &server{
+		opts:  opts,
+		mux:   http.NewServeMux(),
+		slots: make(chan struct{}, opts.MaxJobs),
+		jobs:  map[string]*job{},
+	}
+	s.mux.HandleFunc("/jobs", s.handleJobs)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
+	s.mux.HandleFunc("/score", s.handleScore)
+	s.mux.HandleFunc("/diff", s.handleDiff)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// The statuses of a job
+const (
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+type job struct {
+	id      string
+	problem *arrange.Problem
+	timeout time.Duration
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	status   string
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	result   *arrange.Result
+	err      error
+
+	// How far the search had got when it last found a better arrangement
+	statesTried   int
+	restarts      int
+	visitedMemory int64
+
+	// Closed and replaced whenever the job changes, to wake up anything waiting for it to
+	changed chan struct{}
+}
+
+// The JSON form of a job
+type jsonJob struct {
+	ID          string           `json:"id"`
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	StartedAt   *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time       `json:"finishedAt,omitempty"`
+	ElapsedSecs float64          `json:"elapsedSecs"`
+	Error       string           `json:"error,omitempty"`
+	Result      *jsonArrangement `json:"result,omitempty"`
+
+	// The score of the best arrangement found so far, and how far the search had got when it was found
+	BestScore   arrange.Score `json:"bestScore,omitempty"`
+	StatesTried int           `json:"statesTried"`
+	Restarts    int           `json:"restarts"`
+
+	// Roughly how many bytes the search is using to remember the states it has explored
+	VisitedMemoryBytes int64 `json:"visitedMemoryBytes"`
+}
+
+// update changes the job while locked and wakes up anything waiting for it to change.
+func (j *job) update(f func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f()
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// snapshot returns the job as JSON, and a channel that's closed when it next changes.
+func (j *job) snapshot(withResult bool) (jsonJob, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jj := jsonJob{ID: j.id, Status: j.status, CreatedAt: j.created}
+	if !j.started.IsZero() {
+		started := j.started
+		jj.StartedAt = &started
+		end := time.Now()
+		if !j.finished.IsZero() {
+			finished := j.finished
+			jj.FinishedAt = &finished
+			end = finished
+		}
+		jj.ElapsedSecs = end.Sub(started).Seconds()
+	}
+	if j.err != nil {
+		jj.Error = j.err.Error()
+	}
+	jj.StatesTried, jj.Restarts, jj.VisitedMemoryBytes = j.statesTried, j.restarts, j.visitedMemory
+	if j.result != nil {
+		jj.BestScore = j.result.Score
+	}
+	if withResult && j.result != nil {
+		arrangement := newJSONArrangement(j.result, nil)
+		jj.Result = &arrangement
+	}
+	return jj, j.changed
+}
+
+// run waits for a slot, then arranges the job's problem.
+func (s *server) run(ctx context.Context, j *job) {
+	defer j.cancel()
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		j.update(func() {
+			j.status = jobCanceled
+			j.finished = time.Now()
+		})
+		return
+	}
+
+	j.update(func() {
+		j.status = jobRunning
+		j.started = time.Now()
+	})
+
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	p := j.problem
+	opts := p.Options
+	opts.Timeout = 0
+	opts.Progress = func(progress arrange.Progress) {
+		j.update(func() {
+			j.result = progress.Best
+			j.statesTried, j.restarts = progress.StatesTried, progress.Restarts
+			j.visitedMemory = progress.VisitedMemory
+		})
+	}
+	result, err := arrange.Arrange(ctx, p.Items, p.Rules, p.Groups, opts)
+
+	j.update(func() {
+		j.finished = time.Now()
+		j.err = err
+		if result != nil {
+			j.result = result
+		}
+		switch {
+		case err != nil:
+			j.status = jobFailed
+		case ctx.Err() == context.Canceled:
+			j.status = jobCanceled
+		default:
+			j.status = jobDone
+		}
+	})
+}
+
+// handleJobs handles submitting jobs.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST to submit a problem")
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	problem, err := arrange.ReadProblemJSON(bytes.NewReader(body), "problem")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := prepareProblem(problem); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	timeout := problem.Options.Timeout
+	if timeout == 0 {
+		timeout = s.opts.DefaultTimeout
+	}
+	if s.opts.MaxTimeout != 0 && timeout > s.opts.MaxTimeout {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("timeoutSecs may be at most %v", s.opts.MaxTimeout.Seconds()))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:      newJobID(),
+		problem: problem,
+		timeout: timeout,
+		cancel:  cancel,
+		status:  jobQueued,
+		created: time.Now(),
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.removeExpiredJobs()
+	if s.unfinishedJobs() >= s.opts.MaxJobs+s.opts.MaxQueued {
+		s.mu.Unlock()
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, "too many jobs are waiting to run, try again later")
+		return
+	}
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	go s.run(ctx, j)
+
+	jj, _ := j.snapshot(false)
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, jj)
+}
+
+// prepareProblem fills in and cleans up a submitted problem the same way as the command line does.
+func prepareProblem(problem *arrange.Problem) error {
+	if len(problem.Items) == 0 || len(problem.Rules) == 0 || len(problem.Groups) == 0 {
+		return fmt.Errorf("items, rules and groups are required")
+	}
+	return normalizeProblem(problem)
+}
+
+// normalizeProblem normalizes the items' tags as the rules say. Rules may not read synonyms from a mapFile, since that
+// would let clients read files on the server.
+func normalizeProblem(problem *arrange.Problem) error {
+	for i, rule := range problem.Rules {
+		if _, ok := rule.Params["mapFile"]; ok {
+			return fmt.Errorf("problem at rules[%d].params.mapFile: is not allowed by the server, list synonyms with map instead", i)
+		}
+	}
+	norms, err := arrange.NormalizationsFromRules(problem.Rules)
+	if err != nil {
+		return err
+	}
+	arrange.NormalizeItems(problem.Items, norms)
+	return nil
+}
+
+// removeExpiredJobs forgets finished jobs older than the JobTTL. s.mu must be held.
+func (s *server) removeExpiredJobs() {
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		expired := !j.finished.IsZero() && time.Since(j.finished) > s.opts.JobTTL
+		j.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// unfinishedJobs returns how many jobs are running or waiting for a slot. s.mu must be held.
+func (s *server) unfinishedJobs() int {
+	var n int
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		if j.finished.IsZero() {
+			n++
+		}
+		j.mu.Unlock()
+	}
+	return n
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// handleJob handles the requests for one job, /jobs/{id} and below.
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	s.mu.Lock()
+	j := s.jobs[parts[0]]
+	s.mu.Unlock()
+	if j == nil || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+
+	var action string
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		jj, _ := j.snapshot(true)
+		writeJSON(w, http.StatusOK, jj)
+
+	case action == "" && r.Method == http.MethodDelete:
+		j.cancel()
+		jj, _ := j.snapshot(false)
+		writeJSON(w, http.StatusAccepted, jj)
+
+	case action == "events" && r.Method == http.MethodGet:
+		s.streamEvents(w, r, j)
+
+	case action == "best" && r.Method == http.MethodGet:
+		j.mu.Lock()
+		result := j.result
+		j.mu.Unlock()
+		if result == nil {
+			writeError(w, http.StatusNotFound, "no arrangement has been found yet")
+			return
+		}
+		writeJSON(w, http.StatusOK, newJSONArrangement(result, nil))
+
+	case action == "" || action == "events" || action == "best":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// streamEvents sends a "status" event with the job (without its result) whenever it changes, including when a better
+// arrangement is found, until it finishes or the client goes away.
+func (s *server) streamEvents(w http.ResponseWriter, r *http.Request, j *job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		jj, changed := j.snapshot(false)
+		data, _ := json.Marshal(jj)
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		flusher.Flush()
+		if jj.FinishedAt != nil {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// jsonArrangementInput is an arrangement given to the API. It has the same form as jsonArrangement, but only group
+// names and item IDs are used.
+type jsonArrangementInput struct {
+	Groups []struct {
+		Name  string `json:"name"`
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	} `json:"groups"`
+}
+
+// handleScore scores an arrangement of the problem's items, with the problem's rules and group sizes.
+func (s *server) handleScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req struct {
+		Problem     json.RawMessage      `json:"problem"`
+		Arrangement jsonArrangementInput `json:"arrangement"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	problem, err := arrange.ReadProblemJSON(bytes.NewReader(req.Problem), "problem")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := normalizeProblem(problem); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	itemsByID := map[string]*arrange.Item{}
+	for _, item := range problem.Items {
+		itemsByID[item.ID] = item
+	}
+	var groups []*arrange.Group
+	for _, g := range req.Arrangement.Groups {
+		group := &arrange.Group{Name: g.Name}
+		for _, problemGroup := range problem.Groups {
+			if problemGroup.Name == g.Name {
+				group.MinSize, group.MaxSize, group.Quotas, group.Tags = problemGroup.MinSize, problemGroup.MaxSize, problemGroup.Quotas, problemGroup.Tags
+			}
+		}
+		for _, i := range g.Items {
+			item, ok := itemsByID[i.ID]
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("item %q of group %q isn't in the problem", i.ID, g.Name))
+				return
+			}
+			group.Items = append(group.Items, item)
+		}
+		if group.MaxSize == 0 {
+			group.MaxSize = len(group.Items)
+		}
+		groups = append(groups, group)
+	}
+
+	score, breakdown, err := arrange.ScoreArrangement(problem.Rules, groups, problem.Options)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newJSONArrangement(&arrange.Result{Groups: groups, Score: score, Breakdown: breakdown}, nil))
+}
+
+// The result of comparing two arrangements
+type jsonDiff struct {
+	// Items that are in a different group (or only in one of the arrangements)
+	Moved []jsonMove `json:"moved"`
+
+	// How many pairs of items are together in both arrangements, and in only one of them
+	PairsKept    int `json:"pairsKept"`
+	PairsBroken  int `json:"pairsBroken"`
+	PairsCreated int `json:"pairsCreated"`
+}
+
+type jsonMove struct {
+	ID   string `json:"id"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// handleDiff compares two arrangements by item ID and group name.
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req struct {
+		A jsonArrangementInput `json:"a"`
+		B jsonArrangementInput `json:"b"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	writeJSON(w, http.StatusOK, diffArrangements(req.A, req.B))
+}
+
+func diffArrangements(a, b jsonArrangementInput) jsonDiff {
+	groupsA, groupsB := groupByItem(a), groupByItem(b)
+	diff := jsonDiff{Moved: []jsonMove{}}
+
+	var ids []string
+	for id := range groupsA {
+		ids = append(ids, id)
+	}
+	for id := range groupsB {
+		if _, ok := groupsA[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for i, id1 := range ids {
+		if groupsA[id1] != groupsB[id1] {
+			diff.Moved = append(diff.Moved, jsonMove{ID: id1, From: groupsA[id1], To: groupsB[id1]})
+		}
+		for _, id2 := range ids[i+1:] {
+			togetherA := groupsA[id1] != "" && groupsA[id1] == groupsA[id2]
+			togetherB := groupsB[id1] != "" && groupsB[id1] == groupsB[id2]
+			switch {
+			case togetherA && togetherB:
+				diff.PairsKept++
+			case togetherA:
+				diff.PairsBroken++
+			case togetherB:
+				diff.PairsCreated++
+			}
+		}
+	}
+	return diff
+}
+
+// groupByItem maps each item ID to the name of its group.
+func groupByItem(arrangement jsonArrangementInput) map[string]string {
+	groups := map[string]string{}
+	for _, group := range arrangement.Groups {
+		for _, item := range group.Items {
+			groups[item.ID] = group.Name
+		}
+	}
+	return groups
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return nil, false
+	}
+	return body, true
+}
+
+// readJSON decodes the request body into v. Unknown fields are allowed, so that e.g. a job's result can be passed as an
+// arrangement as it is.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, ok := readBody(w, r)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+const testProblem = `{
+	"items": [
+		{"id": "bob", "tags": {"gender": "m"}},
+		{"id": "joe", "tags": {"gender": "m"}},
+		{"id": "sue", "tags": {"gender": "f"}},
+		{"id": "ann", "tags": {"gender": "F "}}
+	],
+	"rules": [{"tagName": "gender", "type": "Sameness", "weight": 1, "params": {"trim": "", "fold": ""}}],
+	"groups": [{"name": "Van", "maxSize": 2}, {"name": "Car", "maxSize": 2}],
+	"options": {"timeoutSecs": 5}
+}`
+
+func doRequest(t *testing.T, s *server, method, path, body string, v interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if v != nil {
+		assert.Equal(t, nil, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	return rec.Code
+}
+
+func TestServeJob(t *testing.T) {
+	s := newServer(serveOptions{MaxJobs: 1, DefaultTimeout: time.Second, MaxTimeout: time.Minute, JobTTL: time.Hour})
+
+	var submitted jsonJob
+	assert.Equal(t, http.StatusAccepted, doRequest(t, s, "POST", "/jobs", testProblem, &submitted))
+	assert.NotEqual(t, "", submitted.ID)
+
+	// The job finishes quickly, since there are only a few arrangements to try
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/jobs/" + submitted.ID + "/events")
+	assert.Equal(t, nil, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var last jsonJob
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			assert.Equal(t, nil, json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), "data: ")), &last))
+		}
+	}
+	assert.Equal(t, jobDone, last.Status)
+
+	var done jsonJob
+	assert.Equal(t, http.StatusOK, doRequest(t, s, "GET", "/jobs/"+submitted.ID, "", &done))
+	assert.Equal(t, jobDone, done.Status)
+	assert.Equal(t, 2, len(done.Result.Groups))
+	// Both groups have one tag value twice
+	assert.Equal(t, 8.0, done.Result.Score[0])
+	assert.Equal(t, done.Result.Score, done.BestScore)
+	assert.T(t, done.StatesTried > 0)
+
+	var best jsonArrangement
+	assert.Equal(t, http.StatusOK, doRequest(t, s, "GET", "/jobs/"+submitted.ID+"/best", "", &best))
+	assert.Equal(t, done.Result.Score, best.Score)
+
+	assert.Equal(t, http.StatusNotFound, doRequest(t, s, "GET", "/jobs/nope", "", nil))
+}
+
+func TestServeRejectsBadProblems(t *testing.T) {
+	s := newServer(serveOptions{MaxJobs: 1, MaxTimeout: time.Second})
+
+	var resp map[string]string
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, s, "POST", "/jobs", `{"items": [{"id": ""}]}`, &resp))
+	assert.Equal(t, "problem at items[0].id: is required", resp["error"])
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, s, "POST", "/jobs", testProblem, &resp))
+	assert.Equal(t, "timeoutSecs may be at most 1", resp["error"])
+
+	// Synonyms can't be read from files on the server
+	withMapFile := strings.Replace(testProblem, `"trim": ""`, `"mapFile": "/etc/passwd"`, 1)
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, s, "POST", "/jobs", withMapFile, &resp))
+	assert.Equal(t, "problem at rules[0].params.mapFile: is not allowed by the server, list synonyms with map instead", resp["error"])
+	assert.Equal(t, http.StatusBadRequest, doRequest(t, s, "POST", "/score", `{"problem": `+withMapFile+`, "arrangement": {}}`, &resp))
+	assert.Equal(t, "problem at rules[0].params.mapFile: is not allowed by the server, list synonyms with map instead", resp["error"])
+}
+
+func TestServeScoreAndDiff(t *testing.T) {
+	s := newServer(serveOptions{MaxJobs: 1})
+
+	var scored jsonArrangement
+	assert.Equal(t, http.StatusOK, doRequest(t, s, "POST", "/score", `{
+		"problem": `+testProblem+`,
+		"arrangement": {"groups": [
+			{"name": "Van", "items": [{"id": "bob"}, {"id": "sue"}]},
+			{"name": "Car", "items": [{"id": "joe"}, {"id": "ann"}]}
+		]}
+	}`, &scored))
+	assert.Equal(t, 4.0, scored.Score[0])
+	assert.Equal(t, "f", scored.Groups[1].Items[1].Tags["gender"])
+
+	var diff jsonDiff
+	assert.Equal(t, http.StatusOK, doRequest(t, s, "POST", "/diff", `{
+		"a": {"groups": [{"name": "Van", "items": [{"id": "bob"}, {"id": "sue"}]}, {"name": "Car", "items": [{"id": "joe"}]}]},
+		"b": {"groups": [{"name": "Van", "items": [{"id": "bob"}, {"id": "joe"}]}, {"name": "Car", "items": [{"id": "sue"}]}]}
+	}`, &diff))
+	assert.Equal(t, jsonDiff{
+		Moved:        []jsonMove{{ID: "joe", From: "Car", To: "Van"}, {ID: "sue", From: "Van", To: "Car"}},
+		PairsBroken:  1,
+		PairsCreated: 1,
+	}, diff)
+}
+
+// longProblem returns a problem that keeps the search busy until it times out.
+func longProblem() string {
+	var items []string
+	for i := 0; i < 32; i++ {
+		items = append(items, fmt.Sprintf(`{"id": "item%d", "tags": {"color": "c%d"}}`, i, i%7))
+	}
+	var groups []string
+	for i := 0; i < 8; i++ {
+		groups = append(groups, fmt.Sprintf(`{"name": "g%d", "maxSize": 4}`, i))
+	}
+	return `{
+		"items": [` + strings.Join(items, ",") + `],
+		"rules": [{"tagName": "color", "type": "Sameness", "weight": 1}],
+		"groups": [` + strings.Join(groups, ",") + `],
+		"options": {"timeoutSecs": 30}
+	}`
+}
+
+func TestServeBestWhileRunning(t *testing.T) {
+	s := newServer(serveOptions{MaxJobs: 1, MaxTimeout: time.Minute, JobTTL: time.Hour})
+
+	var submitted jsonJob
+	assert.Equal(t, http.StatusAccepted, doRequest(t, s, "POST", "/jobs", longProblem(), &submitted))
+	defer doRequest(t, s, "DELETE", "/jobs/"+submitted.ID, "", nil)
+
+	var best jsonArrangement
+	deadline := time.Now().Add(10 * time.Second)
+	for doRequest(t, s, "GET", "/jobs/"+submitted.ID+"/best", "", &best) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("no best arrangement while the job is running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 8, len(best.Groups))
+
+	var running jsonJob
+	assert.Equal(t, http.StatusOK, doRequest(t, s, "GET", "/jobs/"+submitted.ID, "", &running))
+	assert.Equal(t, jobRunning, running.Status)
+}
+
+func TestServeRefusesJobsWhenQueueIsFull(t *testing.T) {
+	s := newServer(serveOptions{MaxJobs: 1, MaxQueued: 1, MaxTimeout: time.Minute, JobTTL: time.Hour})
+
+	var first, second jsonJob
+	assert.Equal(t, http.StatusAccepted, doRequest(t, s, "POST", "/jobs", longProblem(), &first))
+	defer doRequest(t, s, "DELETE", "/jobs/"+first.ID, "", nil)
+	assert.Equal(t, http.StatusAccepted, doRequest(t, s, "POST", "/jobs", longProblem(), &second))
+	defer doRequest(t, s, "DELETE", "/jobs/"+second.ID, "", nil)
+
+	var resp map[string]string
+	assert.Equal(t, http.StatusServiceUnavailable, doRequest(t, s, "POST", "/jobs", longProblem(), &resp))
+	assert.Equal(t, "too many jobs are waiting to run, try again later", resp["error"])
+}
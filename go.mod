@@ -4,7 +4,6 @@ go 1.12
 
 require (
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869
-	github.com/dankinder/handle v1.0.0
 	github.com/davecgh/go-spew v1.1.1
 	github.com/go-openapi/strfmt v0.19.5 // indirect
 	github.com/jedib0t/go-pretty v4.3.0+incompatible
@@ -12,4 +11,5 @@ require (
 	github.com/mattn/go-runewidth v0.0.8 // indirect
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
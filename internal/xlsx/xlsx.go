@@ -0,0 +1,387 @@
+// Package xlsx reads and writes the cell values of Excel (.xlsx) workbooks. Only what arrangeit needs is supported:
+// values are read as text without any formatting, and written with a bold first row and no other styling.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Sheet is one sheet of a workbook.
+type Sheet struct {
+	Name string
+
+	// Rows[i] is the row numbered i+1 in the spreadsheet, and Rows[i][j] is the text of the cell in column j, where
+	// column A is 0. Empty rows are nil, and trailing empty cells may be left out.
+	Rows [][]string
+}
+
+type xmlWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xmlRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlSharedStrings struct {
+	Items []xmlText `xml:"si"`
+}
+
+// xmlText is either plain text or a list of runs of formatted text.
+type xmlText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *xmlText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xmlWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline *xmlText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Read reads every sheet of a workbook, in order.
+func Read(r io.ReaderAt, size int64) ([]*Sheet, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an .xlsx workbook: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var workbook xmlWorkbook
+	if err := readXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xmlRelationships
+	if err := readXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+
+	var sharedStrings []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst xmlSharedStrings
+		if err := readXML(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for i := range sst.Items {
+			sharedStrings = append(sharedStrings, sst.Items[i].String())
+		}
+	}
+
+	var sheets []*Sheet
+	for _, s := range workbook.Sheets {
+		target, ok := targets[s.RID]
+		if !ok {
+			return nil, fmt.Errorf("sheet %q is missing from the workbook", s.Name)
+		}
+		var ws xmlWorksheet
+		if err := readXML(files, target, &ws); err != nil {
+			return nil, err
+		}
+
+		sheet := &Sheet{Name: s.Name}
+		rowNum := 0
+		for _, row := range ws.Rows {
+			rowNum++
+			if row.R != 0 {
+				rowNum = row.R
+			}
+			var values []string
+			col := -1
+			for _, cell := range row.Cells {
+				col++
+				if cell.R != "" {
+					if col, err = columnIndex(cell.R); err != nil {
+						return nil, fmt.Errorf("sheet %q: %v", s.Name, err)
+					}
+				}
+				value, err := cellValue(cell.T, cell.V, cell.Inline, sharedStrings)
+				if err != nil {
+					return nil, fmt.Errorf("sheet %q cell %s: %v", s.Name, cell.R, err)
+				}
+				for len(values) <= col {
+					values = append(values, "")
+				}
+				values[col] = value
+			}
+			for len(sheet.Rows) < rowNum {
+				sheet.Rows = append(sheet.Rows, nil)
+			}
+			sheet.Rows[rowNum-1] = values
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+func readXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("not an .xlsx workbook: %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// columnIndex returns the index of the column in a cell reference like "AB12", where column A is 0.
+func columnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A') + 1
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("bad cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+// columnName returns the letters of the column with the given index, e.g. "AB" for 27.
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+func cellValue(cellType, value string, inline *xmlText, sharedStrings []string) (string, error) {
+	switch cellType {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(sharedStrings) {
+			return "", fmt.Errorf("bad shared string index %q", value)
+		}
+		return sharedStrings[i], nil
+	case "inlineStr":
+		if inline == nil {
+			return "", nil
+		}
+		return inline.String(), nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		// Numbers are stored with full binary precision, e.g. 0.1+0.2 as 0.30000000000000004, so round them to what
+		// Excel shows.
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value, nil
+		}
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	default:
+		// Formula results ("str"), errors ("e") and dates ("d") are used as they are
+		return value, nil
+	}
+}
+
+// Writer writes a workbook.
+type Writer struct {
+	zw     *zip.Writer
+	sheets []string
+	err    error
+}
+
+// NewWriter starts writing a workbook to w. Close must be called once all the sheets have been added.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// AddSheet writes a sheet with the given rows. The first row is written in bold, as a header. Cells that are ints or
+// float64s are written as numbers, and anything else as text. name is adjusted if needed to be a valid sheet name that
+// is different from every sheet already added.
+func (w *Writer) AddSheet(name string, rows [][]interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	name = w.uniqueSheetName(name)
+	w.sheets = append(w.sheets, name)
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		style := ""
+		if i == 0 {
+			style = ` s="1"`
+		}
+		for j, value := range row {
+			ref := columnName(j) + strconv.Itoa(i+1)
+			switch v := value.(type) {
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'g', -1, 64))
+			default:
+				fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+					ref, style, escape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+
+	w.err = w.writeFile(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(w.sheets)), b.String())
+	return w.err
+}
+
+// uniqueSheetName replaces the characters that sheet names can't have, shortens it to the maximum of 31 characters,
+// and adds a number if needed to make it unique.
+func (w *Writer) uniqueSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncate(name, 31)
+	for n := 2; w.hasSheet(candidate); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		candidate = truncate(name, 31-len(suffix)) + suffix
+	}
+	return candidate
+}
+
+func (w *Writer) hasSheet(name string) bool {
+	for _, sheet := range w.sheets {
+		if strings.EqualFold(sheet, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// Close writes the rest of the workbook. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	var contentTypes, workbook, rels strings.Builder
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	for i, name := range w.sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, f := range files {
+		if err := w.writeFile(f.name, f.content); err != nil {
+			return err
+		}
+	}
+	return w.zw.Close()
+}
+
+// stylesXML has the default style (index 0) and bold (index 1).
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func (w *Writer) writeFile(name, content string) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package xlsx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.Equal(t, nil, w.AddSheet("Items", [][]interface{}{
+		{"Name", "age", "note"},
+		{"bob", 15, "<likes & dislikes>"},
+		{"007", 2.5},
+	}))
+	assert.Equal(t, nil, w.AddSheet("a/b: c", nil))
+	assert.Equal(t, nil, w.AddSheet("A_B_ C", nil))
+	assert.Equal(t, nil, w.Close())
+
+	sheets, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(sheets))
+	assert.Equal(t, &Sheet{Name: "Items", Rows: [][]string{
+		{"Name", "age", "note"},
+		{"bob", "15", "<likes & dislikes>"},
+		{"007", "2.5"},
+	}}, sheets[0])
+	assert.Equal(t, "a_b_ c", sheets[1].Name)
+	assert.Equal(t, "A_B_ C (2)", sheets[2].Name)
+}
+
+func TestColumns(t *testing.T) {
+	for _, col := range []int{0, 25, 26, 27, 701, 702} {
+		index, err := columnIndex(columnName(col) + "1")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, col, index)
+	}
+	assert.Equal(t, "AB", columnName(27))
+}
+
+func TestCellValue(t *testing.T) {
+	shared := []string{"zero", "one"}
+	for _, c := range []struct {
+		cellType, value, want string
+	}{
+		{"s", "1", "one"},
+		{"", "0.30000000000000004", "0.3"},
+		{"n", "15", "15"},
+		{"b", "1", "TRUE"},
+		{"str", "formula result", "formula result"},
+	} {
+		got, err := cellValue(c.cellType, c.value, nil, shared)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, c.want, got)
+	}
+	_, err := cellValue("s", "2", nil, shared)
+	assert.NotEqual(t, nil, err)
+}